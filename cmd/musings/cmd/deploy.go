@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/deploy"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the deploy command.
+var (
+	deployTarget string
+	deployDryRun bool
+)
+
+// deployCmd represents the deploy command which uploads the published site.
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Deploy the published site",
+	Long: `Deploy uploads the site in public/ to the configured target. Only files
+whose checksum differs from the previously deployed manifest are uploaded, and
+files removed from the site are deleted from the target.
+
+Targets:
+  s3    Amazon S3 or any S3-compatible storage such as MinIO (deploy.s3)
+  git   A branch of a local git repository, e.g. gh-pages (deploy.git)
+  sftp  A directory on a web server reachable over SSH (deploy.sftp)`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		name := deployTarget
+		if name == "" {
+			name = cfg.Deploy.Target
+		}
+
+		var target deploy.Target
+		opts := deploy.Options{DryRun: deployDryRun}
+		switch name {
+		case "s3":
+			if target, err = deploy.NewS3(cfg.Deploy.S3, cfg.Hosting.CacheControl); err != nil {
+				return err
+			}
+			opts.Delete = cfg.Deploy.S3.Delete
+		case "git":
+			titles, err := postTitles(cfg)
+			if err != nil {
+				return err
+			}
+			if target, err = deploy.NewGit(cfg.Deploy.Git, titles); err != nil {
+				return err
+			}
+			opts.Delete = true
+		case "sftp":
+			sftp, err := deploy.NewSFTP(cfg.Deploy.SFTP)
+			if err != nil {
+				return err
+			}
+			defer sftp.Close()
+			target = sftp
+			opts.Delete = cfg.Deploy.SFTP.Delete
+		default:
+			return fmt.Errorf("unknown deploy target %q", name)
+		}
+
+		plan, err := deploy.Deploy(target, "public", opts)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case plan.Empty():
+			fmt.Println("The deployed site is up to date.")
+		case deployDryRun:
+			fmt.Printf("%d file(s) would be uploaded and %d deleted.\n", len(plan.Upload), len(plan.Delete))
+		default:
+			fmt.Printf("Uploaded %d file(s) and deleted %d.\n", len(plan.Upload), len(plan.Delete))
+		}
+		return nil
+	},
+}
+
+// postTitles maps the page of every post to its title.
+func postTitles(cfg *config.Config) (map[string]string, error) {
+	b := blog.NewBlog("posts")
+	b.GitDates = cfg.Dates.FromGit
+	b.Location = cfg.Location()
+	if err := b.LoadPosts(); err != nil {
+		return nil, fmt.Errorf("error loading posts: %w", err)
+	}
+
+	titles := make(map[string]string, len(b.Posts))
+	for _, post := range b.Posts {
+		titles[post.Slug+".html"] = post.Title
+	}
+	return titles, nil
+}
+
+func init() {
+	deployCmd.Flags().StringVar(&deployTarget, "target", "", "deploy target (default from deploy.target)")
+	deployCmd.Flags().BoolVar(&deployDryRun, "dry-run", false, "show what would be uploaded and deleted")
+}
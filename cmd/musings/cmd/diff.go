@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command which compares two site manifests.
+var diffCmd = &cobra.Command{
+	Use:   "diff [old] [new]",
+	Short: "List the files that differ between two builds",
+	Long: `Diff compares two site manifests and lists the added (A), changed (M) and
+removed (D) files. Each argument is a manifest file, an output directory or the
+URL of a deployed site. With one argument it is compared to the local build in
+public/; without arguments the deployed site at site.base_url is compared to the
+local build.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, to := "", "public"
+		switch len(args) {
+		case 0:
+			cfg, err := config.Load(configPath)
+			if err != nil {
+				return fmt.Errorf("error loading configuration: %w", err)
+			}
+			from = cfg.Site.BaseURL
+		case 1:
+			from = args[0]
+		case 2:
+			from, to = args[0], args[1]
+		}
+
+		to = manifestLocation(to)
+		newer, err := manifest.Load(to)
+		if err != nil {
+			return fmt.Errorf("error loading manifest: %w", err)
+		}
+
+		from = manifestLocation(from)
+		older, err := manifest.Load(from)
+		if errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("No manifest at %s, comparing against an empty site.\n", from)
+			older = &manifest.Manifest{}
+		} else if err != nil {
+			return fmt.Errorf("error loading manifest: %w", err)
+		}
+
+		diff := manifest.Compare(older, newer)
+		for _, e := range diff.Added {
+			fmt.Printf("A  %s (%d bytes)\n", e.Path, e.Size)
+		}
+		for _, e := range diff.Changed {
+			fmt.Printf("M  %s (%d bytes)\n", e.Path, e.Size)
+		}
+		for _, e := range diff.Removed {
+			fmt.Printf("D  %s\n", e.Path)
+		}
+
+		if diff.Empty() {
+			fmt.Println("No differences.")
+		} else {
+			fmt.Printf("%d added, %d changed, %d removed.\n", len(diff.Added), len(diff.Changed), len(diff.Removed))
+		}
+		return nil
+	},
+}
+
+// manifestLocation resolves a diff argument to the location of a manifest:
+// site URLs and output directories are completed with the manifest name.
+func manifestLocation(arg string) string {
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		if strings.HasSuffix(arg, ".json") {
+			return arg
+		}
+		return strings.TrimSuffix(arg, "/") + "/" + manifest.FileName
+	}
+	if info, err := os.Stat(arg); err == nil && info.IsDir() {
+		return filepath.Join(arg, manifest.FileName)
+	}
+	return arg
+}
@@ -0,0 +1,85 @@
+// Package cmd implements the command-line interface for the musings application.
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/site"
+	"github.com/spf13/cobra"
+)
+
+// fixDryRun makes the fix command only show the changes it would make.
+var fixDryRun bool
+
+// fixCmd represents the fix command which writes missing metadata back into
+// the frontmatter of markdown posts.
+var fixCmd = &cobra.Command{
+	Use:   "fix [files...]",
+	Short: "Add missing dates and normalize post frontmatter",
+	Long: `Fix adds missing ID, CreatedDate and UpdatedDate fields to the frontmatter
+of markdown posts and normalizes the frontmatter. A diff of every change is
+shown. Without arguments all posts in the posts directory are fixed.
+
+The ID keeps feed entries stable when a post is renamed. It is made from
+the site host, the creation date and the slug.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		b := blog.NewBlog("posts")
+		b.GitDates = cfg.Dates.FromGit
+		b.Location = cfg.Location()
+
+		files := args
+		if len(files) == 0 {
+			if files, err = b.PostFiles(); err != nil {
+				return fmt.Errorf("error listing posts: %w", err)
+			}
+		}
+
+		if err := b.LoadPosts(); err != nil {
+			return fmt.Errorf("error loading posts: %w", err)
+		}
+		ids := site.FeedIDs(cfg, b.Posts)
+
+		changed := 0
+		for _, file := range files {
+			created, updated := b.DefaultDates(file)
+			result, err := blog.FixPost(file, created, updated, ids[filepath.Clean(file)])
+			if err != nil {
+				return fmt.Errorf("error fixing %s: %w", file, err)
+			}
+			if !result.Changed() {
+				continue
+			}
+
+			changed++
+			fmt.Print(result.Diff())
+			if fixDryRun {
+				continue
+			}
+			if err := blog.WriteFix(result); err != nil {
+				return fmt.Errorf("error writing %s: %w", file, err)
+			}
+		}
+
+		switch {
+		case changed == 0:
+			fmt.Println("All posts are up to date.")
+		case fixDryRun:
+			fmt.Printf("%d post(s) would be fixed.\n", changed)
+		default:
+			fmt.Printf("Fixed %d post(s).\n", changed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	fixCmd.Flags().BoolVar(&fixDryRun, "dry-run", false, "show the changes without writing them")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/infra"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the infra init command.
+var (
+	infraProvider string
+	infraDir      string
+	infraForce    bool
+)
+
+// infraCmd groups the commands that manage the hosting infrastructure.
+var infraCmd = &cobra.Command{
+	Use:   "infra",
+	Short: "Manage the infrastructure hosting the site",
+}
+
+// infraInitCmd represents the infra init command which writes a Terraform
+// module for hosting the site.
+var infraInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a Terraform module for hosting the site",
+	Long: `Init writes a Terraform module that creates the hosting for the site,
+with its variables filled from the configuration in terraform.tfvars.
+
+Providers:
+  aws  S3 bucket, CloudFront distribution with origin access control, ACM
+       certificate and Route53 records. The domain is taken from
+       site.base_url, the bucket, region and prefix from deploy.s3, the
+       hosted zone from infra.zone (required for subdomains) and the price
+       class from infra.price_class. The CloudFront Function written by
+       publish is copied into the module.
+
+Existing module files are kept unless --force is given, but terraform.tfvars
+and the copied CloudFront Function are always refreshed: run init again after
+a publish that changed redirects or headers, then terraform apply.
+
+Apply the module with terraform init and terraform apply, then upload the
+site with 'musings deploy'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		dir := infraDir
+		if dir == "" {
+			dir = cfg.Infra.Dir
+		}
+
+		written, kept, err := infra.Init(cfg, infra.Options{Provider: infraProvider, Dir: dir, Force: infraForce})
+		if err != nil {
+			return err
+		}
+		for _, path := range written {
+			fmt.Printf("Wrote: %s\n", path)
+		}
+		for _, path := range kept {
+			fmt.Printf("Kept: %s (use --force to overwrite)\n", path)
+		}
+		fmt.Printf("Run 'terraform -chdir=%s init' and 'terraform -chdir=%s apply' to create the infrastructure.\n", dir, dir)
+		return nil
+	},
+}
+
+func init() {
+	infraInitCmd.Flags().StringVar(&infraProvider, "provider", "aws", "cloud provider to write the module for")
+	infraInitCmd.Flags().StringVar(&infraDir, "dir", "", "directory to write the module to (default from infra.dir)")
+	infraInitCmd.Flags().BoolVar(&infraForce, "force", false, "overwrite existing files")
+	infraCmd.AddCommand(infraInitCmd)
+}
@@ -5,37 +5,55 @@ import (
 	"fmt"
 
 	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
 	"github.com/m4xw311/musing/internal/site"
 	"github.com/spf13/cobra"
 )
 
+// publishClean makes the publish command empty the output directory first.
+var publishClean bool
+
 // publishCmd represents the publish command which generates a static website
 // from markdown blog posts.
 var publishCmd = &cobra.Command{
 	Use:   "publish",
 	Short: "Publish blog posts to static website",
-	Long:  `Publish blog posts written in markdown to a static website.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Publish blog posts written in markdown to a static website.
+Files in the output directory that the build did not produce are removed,
+except for the paths protected in the configuration such as CNAME.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("Publishing blog posts...")
 
+		// Load site configuration
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
 		// Create blog instance
 		b := blog.NewBlog("posts")
+		b.GitDates = cfg.Dates.FromGit
+		b.Location = cfg.Location()
 
 		// Load existing posts
 		if err := b.LoadPosts(); err != nil {
-			fmt.Printf("Error loading posts: %v\n", err)
-			return
+			return fmt.Errorf("error loading posts: %w", err)
 		}
 
 		// Create static site generator
-		s := site.NewStaticSiteGenerator("posts", "public")
+		s := site.NewStaticSiteGenerator("posts", "public", cfg)
+		s.Clean = publishClean
 
 		// Generate site
 		if err := s.Generate(); err != nil {
-			fmt.Printf("Error generating site: %v\n", err)
-			return
+			return fmt.Errorf("error generating site: %w", err)
 		}
 
 		fmt.Println("Blog posts published successfully to public/ directory!")
+		return nil
 	},
 }
+
+func init() {
+	publishCmd.Flags().BoolVar(&publishClean, "clean", false, "empty the output directory before building")
+}
@@ -5,9 +5,13 @@
 package cmd
 
 import (
+	"github.com/m4xw311/musing/internal/config"
 	"github.com/spf13/cobra"
 )
 
+// configPath is the path to the site configuration file, set by the --config flag.
+var configPath string
+
 var rootCmd = &cobra.Command{
 	Use:   "musings",
 	Short: "A tool to publish markdown-based static blogs",
@@ -22,6 +26,13 @@ func Execute() error {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", config.DefaultPath, "path to the site configuration file")
+
 	rootCmd.AddCommand(publishCmd)
 	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(fixCmd)
+	rootCmd.AddCommand(vendorCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(infraCmd)
 }
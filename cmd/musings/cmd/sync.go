@@ -2,29 +2,257 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
 
 	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/syndicate"
 	"github.com/spf13/cobra"
 )
 
+// Flags of the sync command.
+var (
+	syncDryRun   bool
+	syncPlatform string
+)
+
+// syncDone holds the past tense of every kind of sync action.
+var syncDone = map[syndicate.ActionKind]string{
+	syndicate.Create: "Created",
+	syndicate.Update: "Updated",
+	syndicate.Delete: "Deleted",
+	syndicate.Link:   "Linked",
+}
+
 // syncCmd represents the sync command which syncs blog posts to external platforms.
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync blog posts to external platforms",
-	Long:  `Sync blog posts to platforms like Substack and Medium.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Syncing blog posts to external platforms...")
+	Long: `Sync publishes posts to the platforms listed in their Syndicate
+frontmatter key, e.g. "Syndicate: [devto, medium]". Posts missing on a
+platform are created and changed posts are updated, with the canonical URL
+pointing at the site. With sync.delete set, copies of posts that no longer
+syndicate to a platform are removed. Use --dry-run to only show the plan.
+
+The remote copy of every post and the hash of the content last sent are
+recorded in sync.state, so later syncs only update changed posts and never
+create a post twice. Posts that already exist on a platform are linked to
+their local post on the first sync. 'musings sync status' shows the state.
+
+Platforms:
+  devto   DEV (dev.to); the API key is read from DEVTO_API_KEY
+  medium  Medium; the integration token is read from MEDIUM_TOKEN. Medium
+          cannot update or delete posts through its API.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+		if err := syndicate.CheckBaseURL(cfg.Site.BaseURL); err != nil {
+			return err
+		}
 
-		// Create blog instance
 		b := blog.NewBlog("posts")
+		b.GitDates = cfg.Dates.FromGit
+		b.Location = cfg.Location()
+		if err := b.LoadPosts(); err != nil {
+			return fmt.Errorf("error loading posts: %w", err)
+		}
+
+		state, err := syndicate.LoadState(cfg.Sync.State)
+		if err != nil {
+			return fmt.Errorf("error loading sync state: %w", err)
+		}
+
+		// Group the published posts by the platforms they syndicate to.
+		// Platforms with recorded posts may have copies to delete.
+		posts := make(map[string][]syndicate.Post)
+		if syncPlatform != "" {
+			posts[syncPlatform] = nil
+		} else if cfg.Sync.Delete {
+			for _, name := range state.Platforms() {
+				posts[name] = nil
+			}
+		}
+		for _, post := range b.Posts {
+			if !post.Published {
+				continue
+			}
+			for _, name := range post.Syndicate {
+				if syncPlatform == "" || name == syncPlatform {
+					posts[name] = append(posts[name], syndicate.FromBlog(post, cfg.Site.BaseURL, cfg.Sync.Draft))
+				}
+			}
+		}
+		if len(posts) == 0 {
+			fmt.Println("No posts to sync. Add platforms to a post with 'Syndicate: [devto, medium]'.")
+			return nil
+		}
+		names := make([]string, 0, len(posts))
+		for name := range posts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Plan the changes on every platform before making any
+		type platformPlan struct {
+			platform syndicate.Platform
+			actions  []syndicate.Action
+		}
+		var plans []platformPlan
+		opts := syndicate.Options{BaseURL: cfg.Site.BaseURL, Delete: cfg.Sync.Delete}
+		changes := 0
+		for _, name := range names {
+			platform, err := syndicate.New(name, cfg.Sync)
+			if err != nil {
+				return err
+			}
+			if err := platform.Authenticate(); err != nil {
+				return fmt.Errorf("error authenticating with %s: %w", name, err)
+			}
+			remote, err := platform.List()
+			if err != nil {
+				return fmt.Errorf("error listing posts on %s: %w", name, err)
+			}
+			actions := syndicate.BuildPlan(name, posts[name], remote, state, opts)
+			plans = append(plans, platformPlan{platform, actions})
+			changes += len(actions)
+		}
+		if changes == 0 {
+			fmt.Println("All platforms are up to date.")
+			return nil
+		}
+
+		fmt.Println("Sync plan:")
+		for _, plan := range plans {
+			for _, action := range plan.actions {
+				fmt.Printf("  %-6s %-7s %s\n", action.Kind, plan.platform.Name(), action.Title())
+			}
+		}
+		if syncDryRun {
+			fmt.Printf("%d change(s) would be made.\n", changes)
+			return nil
+		}
+
+		// Save the state after every action, so posts created before an
+		// error are not created again
+		made := 0
+		for _, plan := range plans {
+			name := plan.platform.Name()
+			for _, action := range plan.actions {
+				post, err := syndicate.Apply(plan.platform, action)
+				if errors.Is(err, syndicate.ErrUnsupported) {
+					fmt.Printf("Skipped %s on %s: %s (%v)\n", action.Kind, name, action.Title(), err)
+					continue
+				} else if err != nil {
+					return fmt.Errorf("error syncing %q to %s: %w", action.Title(), name, err)
+				}
+				fmt.Printf("%s on %s: %s (%s)\n", syncDone[action.Kind], name, action.Title(), post.URL)
+				made++
 
-		// Load existing posts
+				state.Applied(name, action, post)
+				if err := state.Save(); err != nil {
+					return fmt.Errorf("error saving sync state: %w", err)
+				}
+			}
+		}
+		fmt.Printf("Made %d change(s).\n", made)
+		return nil
+	},
+}
+
+// syncStatusCmd represents the sync status command which shows the sync
+// state of the posts.
+var syncStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the sync state of every post",
+	Long: `Status lists every post syndicated to a platform with the state of its
+remote copy, as recorded by the last sync, without contacting the platforms:
+
+  synced   the copy matches the post
+  changed  the post changed since it was last synced
+  new      the post has not been synced yet
+  removed  the post no longer syndicates to the platform, but has a copy`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return fmt.Errorf("error loading configuration: %w", err)
+		}
+
+		b := blog.NewBlog("posts")
+		b.GitDates = cfg.Dates.FromGit
+		b.Location = cfg.Location()
 		if err := b.LoadPosts(); err != nil {
-			fmt.Printf("Error loading posts: %v\n", err)
-			return
+			return fmt.Errorf("error loading posts: %w", err)
 		}
 
-		fmt.Println("Blog posts synced successfully!")
+		state, err := syndicate.LoadState(cfg.Sync.State)
+		if err != nil {
+			return fmt.Errorf("error loading sync state: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "POST\tPLATFORM\tSTATUS\tSYNCED\tURL")
+		counts := make(map[string]int)
+		row := func(title, platform, status string, rec syndicate.Record) {
+			synced := "-"
+			if !rec.SyncedAt.IsZero() {
+				synced = rec.SyncedAt.In(cfg.Location()).Format("2006-01-02 15:04")
+			}
+			url := rec.URL
+			if url == "" {
+				url = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", title, platform, status, synced, url)
+			counts[status]++
+		}
+
+		syndicated := make(map[[2]string]bool)
+		for _, post := range b.Posts {
+			if !post.Published {
+				continue
+			}
+			for _, name := range post.Syndicate {
+				p := syndicate.FromBlog(post, cfg.Site.BaseURL, cfg.Sync.Draft)
+				syndicated[[2]string{p.Source, name}] = true
+				rec, ok := state.Get(p.Source, name)
+				switch {
+				case !ok:
+					row(p.Title, name, "new", rec)
+				case rec.Hash != syndicate.Hash(p):
+					row(p.Title, name, "changed", rec)
+				default:
+					row(p.Title, name, "synced", rec)
+				}
+			}
+		}
+		for _, name := range state.Platforms() {
+			for _, source := range state.Sources(name) {
+				if !syndicated[[2]string{source, name}] {
+					rec, _ := state.Get(source, name)
+					row(rec.Title, name, "removed", rec)
+				}
+			}
+		}
+
+		if len(counts) == 0 {
+			fmt.Println("No posts to sync. Add platforms to a post with 'Syndicate: [devto, medium]'.")
+			return nil
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("%d synced, %d changed, %d new, %d removed.\n", counts["synced"], counts["changed"], counts["new"], counts["removed"])
+		return nil
 	},
 }
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "show the sync plan without changing anything")
+	syncCmd.Flags().StringVar(&syncPlatform, "platform", "", "only sync to the named platform")
+	syncCmd.AddCommand(syncStatusCmd)
+}
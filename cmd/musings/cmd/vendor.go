@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/m4xw311/musing/internal/site"
+	"github.com/spf13/cobra"
+)
+
+// vendorForce makes the vendor command download assets that are already present.
+var vendorForce bool
+
+// vendorPin makes the vendor command record the hashes of assets that have none.
+var vendorPin bool
+
+// vendorCmd represents the vendor command which downloads the theme's
+// third-party front-end assets so published sites do not depend on CDNs.
+var vendorCmd = &cobra.Command{
+	Use:   "vendor",
+	Short: "Download the theme's front-end assets into the theme",
+	Long: `Vendor downloads the pinned third-party assets listed in the theme's
+vendor manifest, such as Prism and MathJax, into the theme so they are copied
+into the published site. Files are checked against their recorded integrity
+hashes. Assets without a hash are refused unless --pin is given, which
+records the hash of the file as downloaded; check such files before
+committing them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		assets, err := site.LoadVendorAssets()
+		if err != nil {
+			return fmt.Errorf("error loading vendor manifest: %w", err)
+		}
+
+		fetched := 0
+		for i := range assets {
+			ok, err := site.FetchVendorAsset(&assets[i], vendorForce, vendorPin)
+			if err != nil {
+				return fmt.Errorf("error vendoring %s: %w", assets[i].Path, err)
+			}
+			if ok {
+				fetched++
+				fmt.Printf("Vendored: %s\n", assets[i].Path)
+			}
+		}
+
+		if err := site.SaveVendorAssets(assets); err != nil {
+			return fmt.Errorf("error saving vendor manifest: %w", err)
+		}
+
+		fmt.Printf("Downloaded %d of %d asset(s).\n", fetched, len(assets))
+		return nil
+	},
+}
+
+func init() {
+	vendorCmd.Flags().BoolVar(&vendorForce, "force", false, "download assets even if they are already vendored")
+	vendorCmd.Flags().BoolVar(&vendorPin, "pin", false, "record the integrity hash of assets that have none, trusting the downloaded file")
+}
@@ -0,0 +1,107 @@
+package blog
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Author represents the profile of a post author.
+type Author struct {
+	ID      string   `yaml:"-"`       // Key of the author in the authors file
+	Slug    string   `yaml:"-"`       // URL-friendly form of the ID
+	Name    string   `yaml:"name"`    // Display name
+	Bio     string   `yaml:"bio"`     // Short biography
+	Avatar  string   `yaml:"avatar"`  // Path or URL of the avatar image
+	Email   string   `yaml:"email"`   // Contact address, used in feeds
+	Twitter string   `yaml:"twitter"` // Twitter handle including the @, used in social tags
+	URLs    []string `yaml:"urls"`    // Homepage first, then profiles elsewhere
+}
+
+// URL returns the author's primary URL, or an empty string if there is none.
+func (a Author) URL() string {
+	if len(a.URLs) == 0 {
+		return ""
+	}
+	return a.URLs[0]
+}
+
+// LoadAuthors reads author profiles from the YAML file at path, keyed by
+// author ID. A missing file is not an error and yields no profiles.
+func LoadAuthors(path string) (map[string]Author, error) {
+	authors := make(map[string]Author)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return authors, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &authors); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	for id, author := range authors {
+		author.ID = id
+		author.Slug = createSlug(id)
+		if author.Name == "" {
+			author.Name = id
+		}
+		authors[id] = author
+	}
+
+	return authors, nil
+}
+
+// author returns the profile for id, or a minimal profile named after the ID
+// when the authors file does not describe it.
+func (b *Blog) author(id string) Author {
+	if author, ok := b.Authors[id]; ok {
+		return author
+	}
+	return Author{ID: id, Slug: createSlug(id), Name: id}
+}
+
+// PostAuthors returns every author with at least one published post, sorted
+// by name.
+func (b *Blog) PostAuthors() []Author {
+	seen := make(map[string]bool)
+	var authors []Author
+	for _, post := range b.Posts {
+		if !post.Published {
+			continue
+		}
+		for _, author := range post.Authors {
+			if !seen[author.ID] {
+				seen[author.ID] = true
+				authors = append(authors, author)
+			}
+		}
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		return authors[i].Name < authors[j].Name
+	})
+	return authors
+}
+
+// PostsByAuthor returns the published posts written by the author with the
+// given ID, in the blog's order. Drafts are left out like in the feeds.
+func (b *Blog) PostsByAuthor(id string) []Post {
+	var posts []Post
+	for _, post := range b.Posts {
+		if !post.Published {
+			continue
+		}
+		for _, author := range post.Authors {
+			if author.ID == id {
+				posts = append(posts, post)
+				break
+			}
+		}
+	}
+	return posts
+}
@@ -0,0 +1,24 @@
+package blog
+
+import "testing"
+
+func TestPostsByAuthorSkipsDrafts(t *testing.T) {
+	maxAuthor := Author{ID: "max", Name: "Max"}
+	janeAuthor := Author{ID: "jane", Name: "Jane"}
+	b := &Blog{Posts: []Post{
+		{Title: "Published", Published: true, Authors: []Author{maxAuthor}},
+		{Title: "Draft", Authors: []Author{maxAuthor}},
+		{Title: "Only draft", Authors: []Author{janeAuthor}},
+	}}
+
+	posts := b.PostsByAuthor("max")
+	if len(posts) != 1 || posts[0].Title != "Published" {
+		t.Errorf("PostsByAuthor(max) = %v, want only the published post", posts)
+	}
+	if posts := b.PostsByAuthor("jane"); len(posts) != 0 {
+		t.Errorf("PostsByAuthor(jane) = %v, want none", posts)
+	}
+	if authors := b.PostAuthors(); len(authors) != 1 || authors[0].ID != "max" {
+		t.Errorf("PostAuthors() = %v, want only max", authors)
+	}
+}
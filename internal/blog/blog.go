@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -21,6 +22,9 @@ import (
 	"github.com/gomarkdown/markdown/parser"
 )
 
+// DateLayout is the layout of the CreatedDate and UpdatedDate frontmatter fields.
+const DateLayout = "2006-01-02 15:04:05"
+
 // Post represents a blog post with metadata and content.
 type Post struct {
 	Title              string        // Extracted from the first # Heading
@@ -29,17 +33,59 @@ type Post struct {
 	ContentSnippet     string        // Snippet of the content for cards
 	ContentSnippetHTML template.HTML // HTML version of the content snippet
 	CreatedDate        time.Time     // Parsed from frontmatter
+	CreatedByBuild     bool          // CreatedDate is the build time, as neither the frontmatter nor git history has one
 	UpdatedDate        time.Time     // Parsed from frontmatter
 	Slug               string        // Derived from title
+	Aliases            []string      // Earlier slugs or paths that redirect to the post
+	Syndicate          []string      // Platforms the post is synced to, e.g. devto or medium
+	ID                 string        // Optional stable feed ID from frontmatter
+	SourcePath         string        // Path of the markdown file the post was parsed from
+	Section            string        // Top-level directory below the posts directory, empty at the root
 	Tags               []string
+	Authors            []Author // Resolved from the Author/Authors frontmatter keys
+	Description        string   // Optional summary for search engines and social previews
+	Image              string   // Cover image path relative to the site root, or a URL
+	Audio              *Audio   // Audio attachment, nil for posts without one
+	Episode            int      // Podcast episode number, 0 if not set
+	Explicit           bool     // Whether the podcast episode contains explicit content
 	Published          bool
-	ReadingTime        int // Estimated reading time in minutes
+	ReadingTime        int        // Estimated reading time in minutes
+	Related            []PostRef  // Most similar posts, see Blog.ComputeRelated
+	Series             string     // Name of the series the post belongs to
+	SeriesPart         int        // Part number within the series, 0 if not set
+	SeriesNav          *SeriesNav // Position within the series, nil if not in one
+
+	createdDefaulted bool // CreatedDate is the default passed to parsePost
+}
+
+// Audio describes the audio attachment of a post, published as a podcast episode.
+type Audio struct {
+	File     string // Path relative to the site root, or a URL
+	Duration string // Playing time as HH:MM:SS, MM:SS or seconds
+	Size     int64  // Size in bytes, 0 if unknown
 }
 
 // Blog represents a collection of blog posts.
 type Blog struct {
-	Posts []Post
-	Path  string
+	Posts  []Post
+	Series []*Series // Series of posts, grouped by LoadPosts
+	Path   string
+
+	// GitDates derives missing CreatedDate/UpdatedDate values from the first
+	// and last commit of each post instead of the current time.
+	GitDates bool
+
+	// Location is the site time zone. Frontmatter dates without an offset are
+	// interpreted in it and all post dates are expressed in it. Nil means UTC.
+	Location *time.Location
+
+	// Authors holds the known author profiles keyed by ID, see LoadAuthors.
+	Authors map[string]Author
+
+	// DefaultAuthor is the author ID used for posts that do not name one.
+	DefaultAuthor string
+
+	history map[string]gitDates // Commit dates by post path, loaded on demand
 }
 
 // NewBlog creates a new blog instance with the specified path.
@@ -69,10 +115,14 @@ func (b *Blog) LoadPosts() error {
 		}
 
 		if !info.IsDir() && filepath.Ext(path) == ".md" {
-			post, err := parsePost(path)
+			created, updated := b.DefaultDates(path)
+			post, err := parsePost(path, created, updated, b.location())
 			if err != nil {
 				return fmt.Errorf("error parsing post %s: %w", path, err)
 			}
+			post.CreatedByBuild = post.createdDefaulted && !b.inHistory(path)
+			b.resolveAuthors(&post)
+			post.Section = b.section(path)
 			b.Posts = append(b.Posts, post)
 			fmt.Printf("Loaded post: %s\n", post.Title)
 		}
@@ -89,9 +139,92 @@ func (b *Blog) LoadPosts() error {
 		return b.Posts[i].CreatedDate.After(b.Posts[j].CreatedDate)
 	})
 
+	b.groupSeries()
+
 	return nil
 }
 
+// DefaultDates returns the creation and update dates used for the post at path
+// when its frontmatter does not set them. With GitDates enabled these are the
+// first and last commit times of the file; otherwise, or for files git does
+// not know about, the creation date is the current time and the update date is
+// zero, meaning it defaults to the creation date.
+func (b *Blog) DefaultDates(path string) (time.Time, time.Time) {
+	if b.GitDates {
+		if b.history == nil {
+			history, err := loadGitDates(b.Path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not read git history of %s: %v\n", b.Path, err)
+				history = make(map[string]gitDates)
+			} else if isShallowRepository(b.Path) {
+				fmt.Fprintf(os.Stderr, "Warning: %s is in a shallow clone, so dates of posts older than its history are wrong (fetch with --unshallow or fetch-depth: 0)\n", b.Path)
+			}
+			b.history = history
+		}
+
+		if d, ok := b.history[filepath.Clean(path)]; ok {
+			return d.Created.In(b.location()), d.Updated.In(b.location())
+		}
+	}
+
+	return time.Now().In(b.location()), time.Time{}
+}
+
+// inHistory reports whether the git history gave the dates of the post at
+// path.
+func (b *Blog) inHistory(path string) bool {
+	_, ok := b.history[filepath.Clean(path)]
+	return ok
+}
+
+// resolveAuthors replaces the author IDs collected from the frontmatter with
+// their profiles, falling back to the default author.
+func (b *Blog) resolveAuthors(post *Post) {
+	if len(post.Authors) == 0 && b.DefaultAuthor != "" {
+		post.Authors = []Author{{ID: b.DefaultAuthor}}
+	}
+	for i, author := range post.Authors {
+		post.Authors[i] = b.author(author.ID)
+	}
+}
+
+// section returns the top-level directory of path below the blog directory,
+// or an empty string for posts at the root.
+func (b *Blog) section(path string) string {
+	rel, err := filepath.Rel(b.Path, path)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[0]
+}
+
+// location returns the site time zone, defaulting to UTC.
+func (b *Blog) location() *time.Location {
+	if b.Location == nil {
+		return time.UTC
+	}
+	return b.Location
+}
+
+// PostFiles returns the paths of all markdown files in the blog directory.
+func (b *Blog) PostFiles() ([]string, error) {
+	var files []string
+	err := filepath.Walk(b.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".md" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
 // CustomMarkdownParser creates a markdown parser with extended features.
 // It enables various markdown extensions including tables, footnotes,
 // strikethrough, and MathJax support.
@@ -189,8 +322,11 @@ func calculateReadingTime(content string) int {
 
 // parsePost parses a markdown file into a Post struct.
 // It extracts frontmatter metadata and converts the content to HTML.
-func parsePost(filePath string) (Post, error) {
-	post := Post{}
+// Frontmatter dates always win; otherwise defaultCreated is used, and
+// defaultUpdated when it is non-zero or the creation date when it is not.
+// Dates without an offset are interpreted in loc.
+func parsePost(filePath string, defaultCreated, defaultUpdated time.Time, loc *time.Location) (Post, error) {
+	post := Post{SourcePath: filePath}
 
 	_, err := os.Stat(filePath)
 	if err != nil {
@@ -206,7 +342,6 @@ func parsePost(filePath string) (Post, error) {
 	scanner := bufio.NewScanner(file)
 	frontmatter := make(map[string]string)
 	contentLines := make([]string, 0)
-	var rawFrontmatterLines []string
 
 	// Parse frontmatter - files start with frontmatter, then "---", then content
 	inFrontmatter := false
@@ -217,7 +352,6 @@ func parsePost(filePath string) (Post, error) {
 		firstLine := scanner.Text()
 		if firstLine == "---" {
 			inFrontmatter = true
-			rawFrontmatterLines = append(rawFrontmatterLines, firstLine)
 		} else {
 			// No frontmatter, just content
 			contentLines = append(contentLines, firstLine)
@@ -233,14 +367,11 @@ func parsePost(filePath string) (Post, error) {
 			// End of frontmatter, start of content
 			inFrontmatter = false
 			frontmatterProcessed = true
-			rawFrontmatterLines = append(rawFrontmatterLines, line)
 			continue
 		}
 
 		// Process frontmatter or content
 		if inFrontmatter {
-			// Collect raw frontmatter lines
-			rawFrontmatterLines = append(rawFrontmatterLines, line)
 			// Parse frontmatter key: value
 			parts := strings.SplitN(line, ":", 2)
 			if len(parts) == 2 {
@@ -258,11 +389,6 @@ func parsePost(filePath string) (Post, error) {
 		return post, err
 	}
 
-	// Always use current time for missing dates to avoid any file timestamp issues
-	defaultTime := time.Now()
-
-	updatedFile := false
-
 	// Not doing this to simplify the work of the user
 	// if title, ok := frontmatter["Title"]; ok {
 	// 	post.Title = title
@@ -275,37 +401,32 @@ func parsePost(filePath string) (Post, error) {
 	contentLines = removeFirstHeading(contentLines)
 
 	if createdStr, ok := frontmatter["CreatedDate"]; ok {
-		if created, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+		if created, err := ParseDate(createdStr, loc); err == nil {
 			post.CreatedDate = created
 		} else {
-			fmt.Fprintf(os.Stderr, "Invalid CreatedDate in %s. Must be in format '2006-01-02 15:04:05'\n", filePath)
+			fmt.Fprintf(os.Stderr, "Invalid CreatedDate in %s: %v\n", filePath, err)
 		}
 	} else {
-		// If no CreatedDate, use current time
-		post.CreatedDate = defaultTime
-		updatedFile = true
-		fmt.Printf("Missing CreatedDate in %s, setting to current time\n", filePath)
+		// If no CreatedDate, use the default. The source file is never
+		// modified here; `musings fix` writes dates back.
+		post.CreatedDate = defaultCreated
+		post.createdDefaulted = true
+		fmt.Printf("Missing CreatedDate in %s, using %s (run 'musings fix' to add it)\n", filePath, defaultCreated.Format(DateLayout))
 	}
 
 	if updatedStr, ok := frontmatter["UpdatedDate"]; ok {
-		if updated, err := time.Parse("2006-01-02 15:04:05", updatedStr); err == nil {
+		if updated, err := ParseDate(updatedStr, loc); err == nil {
 			post.UpdatedDate = updated
 		} else {
-			fmt.Fprintf(os.Stderr, "Invalid UpdatedDate in %s. Must be in format '2006-01-02 15:04:05'\n", filePath)
+			fmt.Fprintf(os.Stderr, "Invalid UpdatedDate in %s: %v\n", filePath, err)
 		}
 	} else {
-		// If no UpdatedDate, use current time
-		post.UpdatedDate = post.CreatedDate
-		updatedFile = true
-		fmt.Printf("Missing UpdatedDate in %s, setting same as CreatedDate\n", filePath)
-	}
-
-	// Update the file ONLY if we added missing date fields
-	// Do NOT update if both dates already existed and were valid
-	if updatedFile {
-		if err := updatePostFile(filePath, rawFrontmatterLines, frontmatter, contentLines, post); err != nil {
-			fmt.Printf("Warning: Could not update %s with missing date fields: %v\n", filePath, err)
+		// If no UpdatedDate, use the default or fall back to CreatedDate
+		post.UpdatedDate = defaultUpdated
+		if post.UpdatedDate.IsZero() || post.UpdatedDate.Before(post.CreatedDate) {
+			post.UpdatedDate = post.CreatedDate
 		}
+		fmt.Printf("Missing UpdatedDate in %s, using %s (run 'musings fix' to add it)\n", filePath, post.UpdatedDate.Format(DateLayout))
 	}
 
 	if tagsStr, ok := frontmatter["Tags"]; ok {
@@ -316,6 +437,66 @@ func parsePost(filePath string) (Post, error) {
 		post.Tags = tags
 	}
 
+	// Aliases lists the earlier slugs of a renamed post, or other paths
+	// relative to the site root, with or without .html
+	for _, alias := range strings.Split(frontmatter["Aliases"], ",") {
+		alias = strings.TrimSuffix(strings.Trim(strings.TrimSpace(alias), "/"), ".html")
+		if alias != "" {
+			post.Aliases = append(post.Aliases, alias)
+		}
+	}
+
+	// Syndicate takes a comma separated list, optionally in brackets
+	for _, platform := range strings.Split(strings.Trim(frontmatter["Syndicate"], "[]"), ",") {
+		if platform = strings.ToLower(strings.TrimSpace(platform)); platform != "" {
+			post.Syndicate = append(post.Syndicate, platform)
+		}
+	}
+
+	// Author takes a single ID, Authors a comma separated list of IDs
+	authorIDs := frontmatter["Authors"]
+	if authorIDs == "" {
+		authorIDs = frontmatter["Author"]
+	}
+	for _, id := range strings.Split(authorIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			post.Authors = append(post.Authors, Author{ID: id})
+		}
+	}
+
+	post.ID = frontmatter["ID"]
+	post.Description = frontmatter["Description"]
+	post.Image = frontmatter["Image"]
+	if file := frontmatter["Audio"]; file != "" {
+		post.Audio = &Audio{File: file, Duration: frontmatter["AudioDuration"]}
+		if sizeStr, ok := frontmatter["AudioSize"]; ok {
+			if size, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
+				post.Audio.Size = size
+			} else {
+				fmt.Fprintf(os.Stderr, "Invalid AudioSize in %s: %v\n", filePath, err)
+			}
+		}
+	}
+
+	if episodeStr, ok := frontmatter["Episode"]; ok {
+		if episode, err := strconv.Atoi(episodeStr); err == nil {
+			post.Episode = episode
+		} else {
+			fmt.Fprintf(os.Stderr, "Invalid Episode in %s: %v\n", filePath, err)
+		}
+	}
+
+	post.Explicit = strings.ToLower(frontmatter["Explicit"]) == "true"
+
+	post.Series = frontmatter["Series"]
+	if partStr, ok := frontmatter["SeriesPart"]; ok {
+		if part, err := strconv.Atoi(partStr); err == nil {
+			post.SeriesPart = part
+		} else {
+			fmt.Fprintf(os.Stderr, "Invalid SeriesPart in %s: %v\n", filePath, err)
+		}
+	}
+
 	if publishedStr, ok := frontmatter["Published"]; ok {
 		post.Published = strings.ToLower(publishedStr) == "true"
 	}
@@ -341,106 +522,6 @@ func parsePost(filePath string) (Post, error) {
 	return post, nil
 }
 
-// updatePostFile updates the markdown file with missing date fields.
-// It adds CreatedDate and UpdatedDate fields to the frontmatter if they're missing.
-func updatePostFile(filePath string, rawFrontmatterLines []string, frontmatter map[string]string, contentLines []string, post Post) error {
-	// Create the updated content
-	var updatedContent strings.Builder
-
-	// Check if we have frontmatter
-	hasFrontmatter := len(rawFrontmatterLines) > 0
-	hasValidFrontmatterStructure := hasFrontmatter && len(rawFrontmatterLines) >= 2 &&
-		rawFrontmatterLines[0] == "---" && rawFrontmatterLines[len(rawFrontmatterLines)-1] == "---"
-
-	if hasFrontmatter && hasValidFrontmatterStructure {
-		// Check which date fields are missing
-		hasCreatedDate := false
-		hasUpdatedDate := false
-
-		// Check existing frontmatter for date fields
-		for key := range frontmatter {
-			if key == "CreatedDate" {
-				hasCreatedDate = true
-			}
-			if key == "UpdatedDate" {
-				hasUpdatedDate = true
-			}
-		}
-
-		// Write first frontmatter separator
-		updatedContent.WriteString("---\n")
-
-		// Write existing frontmatter lines (excluding separators)
-		for i := 1; i < len(rawFrontmatterLines)-1; i++ {
-			updatedContent.WriteString(rawFrontmatterLines[i] + "\n")
-		}
-
-		// Add missing date fields if needed
-		if !hasCreatedDate {
-			updatedContent.WriteString(fmt.Sprintf("CreatedDate: %s\n", post.CreatedDate.Format("2006-01-02 15:04:05")))
-		}
-
-		if !hasUpdatedDate {
-			updatedContent.WriteString(fmt.Sprintf("UpdatedDate: %s\n", post.UpdatedDate.Format("2006-01-02 15:04:05")))
-		}
-
-		// Write closing frontmatter separator
-		updatedContent.WriteString("---\n")
-	} else if hasFrontmatter {
-		// Frontmatter exists but missing proper separators
-		// Check which date fields are missing
-		hasCreatedDate := false
-		hasUpdatedDate := false
-
-		// Check existing frontmatter for date fields
-		for key := range frontmatter {
-			if key == "CreatedDate" {
-				hasCreatedDate = true
-			}
-			if key == "UpdatedDate" {
-				hasUpdatedDate = true
-			}
-		}
-
-		// Write first frontmatter separator
-		updatedContent.WriteString("---\n")
-
-		// Write existing frontmatter lines
-		for _, line := range rawFrontmatterLines {
-			// Skip any accidental separators in the content
-			if line != "---" {
-				updatedContent.WriteString(line + "\n")
-			}
-		}
-
-		// Add missing date fields if needed
-		if !hasCreatedDate {
-			updatedContent.WriteString(fmt.Sprintf("CreatedDate: %s\n", post.CreatedDate.Format("2006-01-02 15:04:05")))
-		}
-
-		if !hasUpdatedDate {
-			updatedContent.WriteString(fmt.Sprintf("UpdatedDate: %s\n", post.UpdatedDate.Format("2006-01-02 15:04:05")))
-		}
-
-		// Write closing frontmatter separator
-		updatedContent.WriteString("---\n")
-	} else {
-		// No existing frontmatter, create new one
-		updatedContent.WriteString("---\n")
-		updatedContent.WriteString(fmt.Sprintf("CreatedDate: %s\n", post.CreatedDate.Format("2006-01-02 15:04:05")))
-		updatedContent.WriteString(fmt.Sprintf("UpdatedDate: %s\n", post.UpdatedDate.Format("2006-01-02 15:04:05")))
-		updatedContent.WriteString("---\n")
-	}
-
-	// Write content
-	for _, line := range contentLines {
-		updatedContent.WriteString(line + "\n")
-	}
-
-	// Write the updated content back to the file
-	return os.WriteFile(filePath, []byte(updatedContent.String()), 0644)
-}
-
 // extractTitleFromContent extracts the first # heading from content lines.
 func extractTitleFromContent(lines []string) string {
 	for _, line := range lines {
@@ -0,0 +1,44 @@
+package blog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dateLayouts are the frontmatter date layouts accepted by ParseDate, tried in
+// order. Layouts with a zone or offset are parsed as written; the others are
+// interpreted in the site time zone.
+var dateLayouts = []string{
+	DateLayout,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 Z07:00",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ParseDate parses a frontmatter date in any of the supported layouts, such as
+// "2006-01-02 15:04:05", "2006-01-02" or RFC 3339 timestamps. Dates without an
+// offset are interpreted in loc. The result is always expressed in loc.
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date %q, use a format such as '2006-01-02 15:04:05', '2006-01-02' or RFC 3339", value)
+}
@@ -0,0 +1,306 @@
+package blog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// frontmatterOrder is the canonical order of known frontmatter keys.
+// Unknown keys are kept after these, in their original order.
+var frontmatterOrder = []string{"ID", "CreatedDate", "UpdatedDate", "Author", "Authors", "Series", "SeriesPart", "Tags", "Aliases", "Description", "Image", "Audio", "AudioDuration", "AudioSize", "Episode", "Explicit", "Published", "Syndicate"}
+
+// FixResult describes the changes FixPost made to a post's source.
+type FixResult struct {
+	Path     string   // Path of the markdown file
+	Original string   // Source before the fix
+	Fixed    string   // Source after the fix
+	Changes  []string // Human readable list of the changes made
+}
+
+// Changed reports whether fixing the post changed its source.
+func (r FixResult) Changed() bool {
+	return r.Original != r.Fixed
+}
+
+// FixPost adds missing date fields to the frontmatter of the markdown file at
+// filePath and normalizes the frontmatter into "Key: value" lines in canonical
+// order. Missing dates are filled from created and updated as in LoadPosts,
+// see Blog.DefaultDates, and a missing ID from id unless it is empty. The post
+// body, line endings and trailing newline are preserved. The file itself is
+// not modified; use WriteFix to write the result.
+func FixPost(filePath string, created, updated time.Time, id string) (FixResult, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return FixResult{}, err
+	}
+
+	result := FixResult{Path: filePath, Original: string(data)}
+
+	eol := "\n"
+	if strings.Contains(result.Original, "\r\n") {
+		eol = "\r\n"
+	}
+
+	fmLines, body, hasFrontmatter := splitFrontmatter(result.Original, eol)
+	if !hasFrontmatter {
+		result.Changes = append(result.Changes, "added frontmatter")
+	}
+
+	fields, extra, duplicates := parseFrontmatterLines(fmLines)
+	for _, key := range duplicates {
+		result.Changes = append(result.Changes, "removed duplicate "+key+", keeping the last value")
+	}
+
+	if _, ok := fields["ID"]; !ok && id != "" {
+		fields["ID"] = id
+		result.Changes = append(result.Changes, "added ID")
+	}
+	if _, ok := fields["CreatedDate"]; !ok {
+		fields["CreatedDate"] = created.Format(DateLayout)
+		result.Changes = append(result.Changes, "added CreatedDate")
+	}
+	if _, ok := fields["UpdatedDate"]; !ok {
+		fields["UpdatedDate"] = fields["CreatedDate"]
+		if createdDate, err := ParseDate(fields["CreatedDate"], created.Location()); err == nil && updated.After(createdDate) {
+			fields["UpdatedDate"] = updated.Format(DateLayout)
+		}
+		result.Changes = append(result.Changes, "added UpdatedDate")
+	}
+
+	normalizeFrontmatter(fields)
+
+	var b strings.Builder
+	b.WriteString("---" + eol)
+	for _, key := range frontmatterOrder {
+		if value, ok := fields[key]; ok {
+			b.WriteString(key + ": " + value + eol)
+		}
+	}
+	for _, line := range extra {
+		if key, value, ok := splitFrontmatterLine(line); ok {
+			line = key + ": " + value
+		}
+		b.WriteString(line + eol)
+	}
+	b.WriteString("---" + eol)
+	b.WriteString(body)
+
+	result.Fixed = b.String()
+	if result.Changed() && len(result.Changes) == 0 {
+		result.Changes = append(result.Changes, "normalized frontmatter")
+	}
+
+	return result, nil
+}
+
+// WriteFix atomically replaces the file with the fixed source. The content is
+// written to a temporary file in the same directory which is then renamed over
+// the original, so readers never observe a partially written post.
+func WriteFix(result FixResult) error {
+	if !result.Changed() {
+		return nil
+	}
+	return writeFileAtomic(result.Path, []byte(result.Fixed))
+}
+
+// Diff returns a unified-style diff of the frontmatter changes. Only the
+// frontmatter is compared because FixPost never changes the post body.
+func (r FixResult) Diff() string {
+	eol := "\n"
+	if strings.Contains(r.Original, "\r\n") {
+		eol = "\r\n"
+	}
+	before, _, _ := splitFrontmatter(r.Original, eol)
+	after, _, _ := splitFrontmatter(r.Fixed, eol)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", filepath.ToSlash(r.Path), filepath.ToSlash(r.Path))
+	for _, line := range diffLines(before, after) {
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// splitFrontmatter splits source into its frontmatter lines (without the ---
+// separators) and the remaining body. It reports whether frontmatter exists.
+func splitFrontmatter(source, eol string) ([]string, string, bool) {
+	if !strings.HasPrefix(source, "---"+eol) {
+		return nil, source, false
+	}
+
+	rest := source[len("---"+eol):]
+	var lines []string
+	for {
+		idx := strings.Index(rest, eol)
+		var line string
+		if idx < 0 {
+			line = rest
+		} else {
+			line = rest[:idx]
+		}
+
+		if line == "---" {
+			if idx < 0 {
+				return lines, "", true
+			}
+			return lines, rest[idx+len(eol):], true
+		}
+		if idx < 0 {
+			// Unterminated frontmatter; treat the whole file as body
+			return nil, source, false
+		}
+
+		lines = append(lines, line)
+		rest = rest[idx+len(eol):]
+	}
+}
+
+// parseFrontmatterLines collects known keys from the frontmatter lines into a
+// map and returns every other line unchanged, in order. A key given more than
+// once takes its last value, as when the post is loaded, and is listed in
+// duplicates.
+func parseFrontmatterLines(lines []string) (fields map[string]string, extra, duplicates []string) {
+	fields = make(map[string]string)
+	for _, line := range lines {
+		key, value, ok := splitFrontmatterLine(line)
+		if ok && isKnownKey(key) {
+			if _, seen := fields[key]; seen && !slices.Contains(duplicates, key) {
+				duplicates = append(duplicates, key)
+			}
+			fields[key] = value
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		extra = append(extra, line)
+	}
+	return fields, extra, duplicates
+}
+
+// splitFrontmatterLine splits a "Key: value" line into its trimmed parts.
+func splitFrontmatterLine(line string) (string, string, bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), true
+}
+
+// isKnownKey reports whether key is one of the canonical frontmatter keys.
+func isKnownKey(key string) bool {
+	for _, k := range frontmatterOrder {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFrontmatter rewrites the values of known keys into their canonical
+// form. Dates are left as written since any supported layout is valid and
+// rewriting them could drop an explicit offset.
+func normalizeFrontmatter(fields map[string]string) {
+	for _, key := range []string{"Tags", "Aliases"} {
+		list, ok := fields[key]
+		if !ok {
+			continue
+		}
+		parts := strings.Split(list, ",")
+		cleaned := make([]string, 0, len(parts))
+		for _, item := range parts {
+			if item = strings.TrimSpace(item); item != "" {
+				cleaned = append(cleaned, item)
+			}
+		}
+		fields[key] = strings.Join(cleaned, ", ")
+	}
+
+	if published, ok := fields["Published"]; ok {
+		fields["Published"] = fmt.Sprint(strings.ToLower(published) == "true")
+	}
+}
+
+// diffLines returns the lines of a line-based diff between a and b, prefixed
+// with "-", "+" or " " as in a unified diff.
+func diffLines(a, b []string) []string {
+	// Longest common subsequence table; frontmatter is short so the quadratic
+	// cost does not matter
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+a[i])
+			i++
+		default:
+			out = append(out, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+"+b[j])
+	}
+	return out
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// over path, keeping the original file mode.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
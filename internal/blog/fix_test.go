@@ -0,0 +1,39 @@
+package blog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFixPostDuplicateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "post.md")
+	src := "---\nCreatedDate: 2024-01-01\nCreatedDate: 2025-03-04\nPublished: true\n---\n# Post\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The loaded post uses the last value, and so must the fix
+	post, err := parsePost(path, time.Now(), time.Now(), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := post.CreatedDate.Format("2006-01-02"); got != "2025-03-04" {
+		t.Fatalf("loaded CreatedDate %s, want the last value", got)
+	}
+
+	earlier := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := FixPost(path, earlier, earlier, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\nCreatedDate: 2025-03-04\nUpdatedDate: 2025-03-04\nPublished: true\n---\n# Post\n"
+	if result.Fixed != want {
+		t.Errorf("fixed source\n%s\nwant\n%s", result.Fixed, want)
+	}
+	if !strings.Contains(strings.Join(result.Changes, "; "), "removed duplicate CreatedDate") {
+		t.Errorf("changes %q do not mention the duplicate", result.Changes)
+	}
+}
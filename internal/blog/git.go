@@ -0,0 +1,66 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitDates holds the first and last commit times of a file.
+type gitDates struct {
+	Created time.Time // Time of the first commit touching the file
+	Updated time.Time // Time of the last commit touching the file
+}
+
+// loadGitDates reads the commit history of dir with the local git binary and
+// returns the first and last author times of every file, keyed by path joined
+// onto dir. Renames are not followed, so a renamed post starts a new history.
+func loadGitDates(dir string) (map[string]gitDates, error) {
+	// With -z, every commit starts with a \x01-prefixed date and its file
+	// names follow, all terminated by NUL, so names are never quoted
+	cmd := exec.Command("git", "-C", dir, "log", "-z", "--relative", "--name-only", "--no-renames", "--format=%x01%aI", "--", ".")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	dates := make(map[string]gitDates)
+	var current time.Time
+	for _, field := range strings.Split(string(out), "\x00") {
+		field = strings.TrimPrefix(field, "\n")
+		if field == "" {
+			continue
+		}
+
+		if strings.HasPrefix(field, "\x01") {
+			if current, err = time.Parse(time.RFC3339, field[1:]); err != nil {
+				return nil, fmt.Errorf("unexpected git date %q: %w", field[1:], err)
+			}
+			continue
+		}
+
+		// git log lists newest commits first, so the first time seen is the
+		// last update and every later one moves the creation time back
+		path := filepath.Join(dir, filepath.FromSlash(field))
+		d, ok := dates[path]
+		if !ok {
+			d.Updated = current
+		}
+		d.Created = current
+		dates[path] = d
+	}
+
+	return dates, nil
+}
+
+// isShallowRepository reports whether dir is in a shallow clone, whose
+// history lacks the commits that created older files.
+func isShallowRepository(dir string) bool {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
@@ -0,0 +1,60 @@
+package blog
+
+import (
+	"strings"
+)
+
+// Neighbor scopes accepted by Blog.Neighbors.
+const (
+	ScopeAll     = ""        // Neighbors among all published posts
+	ScopeSection = "section" // Neighbors within the same section
+	ScopeTag     = "tag"     // Neighbors sharing the post's first tag
+)
+
+// Neighbors returns the published posts immediately before and after the post
+// at index i in date order: prev is the next older post and next the next
+// newer one. scope restricts the neighbors to the same section or tag.
+func (b *Blog) Neighbors(i int, scope string) (prev, next *PostRef) {
+	post := b.Posts[i]
+
+	inScope := func(other Post) bool {
+		if !other.Published {
+			return false
+		}
+		switch scope {
+		case ScopeSection:
+			return other.Section == post.Section
+		case ScopeTag:
+			return len(post.Tags) > 0 && hasTag(other, post.Tags[0])
+		}
+		return true
+	}
+
+	// Posts are sorted newest first, so older posts follow the current one
+	for j := i + 1; j < len(b.Posts); j++ {
+		if inScope(b.Posts[j]) {
+			ref := b.Posts[j].Ref()
+			prev = &ref
+			break
+		}
+	}
+	for j := i - 1; j >= 0; j-- {
+		if inScope(b.Posts[j]) {
+			ref := b.Posts[j].Ref()
+			next = &ref
+			break
+		}
+	}
+
+	return prev, next
+}
+
+// hasTag reports whether the post has the tag, ignoring case.
+func hasTag(post Post, tag string) bool {
+	for _, t := range post.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package blog
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PostRef is a lightweight reference to another post, used for links between
+// posts without copying their content.
+type PostRef struct {
+	Title       string
+	Slug        string
+	CreatedDate time.Time
+}
+
+// Ref returns a reference to the post.
+func (p Post) Ref() PostRef {
+	return PostRef{Title: p.Title, Slug: p.Slug, CreatedDate: p.CreatedDate}
+}
+
+const (
+	// relatedTagWeight is the share of the similarity score taken by shared
+	// tags; the rest comes from the TF-IDF similarity of the content.
+	relatedTagWeight = 0.5
+
+	// relatedMaxTerms caps the number of highest weighted terms kept per post,
+	// bounding the cost of comparing posts on large blogs.
+	relatedMaxTerms = 64
+
+	// relatedMaxDocFreq drops terms that occur in more than this share of
+	// posts; they say little about similarity and have long posting lists.
+	relatedMaxDocFreq = 0.5
+)
+
+// termWeight is the TF-IDF weight of a term in a post.
+type termWeight struct {
+	term   string
+	weight float64
+}
+
+// posting records the weight of a term in a post.
+type posting struct {
+	post   int
+	weight float64
+}
+
+// ComputeRelated sets Related on every post to up to n other published posts,
+// most similar first. Similarity combines shared tags with the cosine
+// similarity of TF-IDF vectors over the content. Only posts sharing a tag or
+// one of their top terms are compared, so the cost stays close to linear in
+// the number of posts.
+func (b *Blog) ComputeRelated(n int) {
+	for i := range b.Posts {
+		b.Posts[i].Related = nil
+	}
+	if n <= 0 {
+		return
+	}
+
+	// Only published posts are suggested, but every post gets suggestions
+	var candidates []int
+	for i, post := range b.Posts {
+		if post.Published {
+			candidates = append(candidates, i)
+		}
+	}
+
+	vectors := tfidfVectors(b.Posts, candidates)
+
+	// Inverted indexes from term and tag to candidate posts
+	termPosts := make(map[string][]posting)
+	tagPosts := make(map[string][]int)
+	for _, i := range candidates {
+		for _, tw := range vectors[i] {
+			termPosts[tw.term] = append(termPosts[tw.term], posting{i, tw.weight})
+		}
+		for _, tag := range normalizedTags(b.Posts[i].Tags) {
+			tagPosts[tag] = append(tagPosts[tag], i)
+		}
+	}
+
+	tags := make([][]string, len(b.Posts))
+	for i, post := range b.Posts {
+		tags[i] = normalizedTags(post.Tags)
+	}
+
+	// Scores are accumulated in a slice indexed by post and reset through the
+	// list of touched entries, avoiding per-post map allocations
+	scores := make([]float64, len(b.Posts))
+	var touched []int
+	add := func(j int, score float64) {
+		if scores[j] == 0 {
+			touched = append(touched, j)
+		}
+		scores[j] += score
+	}
+
+	for i := range b.Posts {
+		for _, tw := range vectors[i] {
+			for _, p := range termPosts[tw.term] {
+				if p.post != i {
+					add(p.post, (1-relatedTagWeight)*tw.weight*p.weight)
+				}
+			}
+		}
+		for _, tag := range tags[i] {
+			for _, j := range tagPosts[tag] {
+				if j != i {
+					add(j, relatedTagWeight/math.Sqrt(float64(len(tags[i])*len(tags[j]))))
+				}
+			}
+		}
+
+		// Keep the n best scores with an insertion pass instead of sorting
+		// every touched post
+		ranked := make([]int, 0, n+1)
+		for _, j := range touched {
+			pos := len(ranked)
+			for pos > 0 && b.better(j, ranked[pos-1], scores) {
+				pos--
+			}
+			if pos < n {
+				ranked = append(ranked, 0)
+				copy(ranked[pos+1:], ranked[pos:])
+				ranked[pos] = j
+				if len(ranked) > n {
+					ranked = ranked[:n]
+				}
+			}
+		}
+		for _, j := range touched {
+			scores[j] = 0
+		}
+		touched = touched[:0]
+
+		for _, j := range ranked {
+			b.Posts[i].Related = append(b.Posts[i].Related, b.Posts[j].Ref())
+		}
+	}
+}
+
+// better reports whether post j ranks above post k by score, breaking ties
+// by recency so the order is stable.
+func (b *Blog) better(j, k int, scores []float64) bool {
+	if scores[j] != scores[k] {
+		return scores[j] > scores[k]
+	}
+	return b.Posts[j].CreatedDate.After(b.Posts[k].CreatedDate)
+}
+
+// tfidfVectors returns the unit length TF-IDF vector of every post, limited
+// to its highest weighted terms. Document frequencies are counted over the
+// candidate posts only.
+func tfidfVectors(posts []Post, candidates []int) [][]termWeight {
+	counts := make([]map[string]int, len(posts))
+	for i, post := range posts {
+		counts[i] = make(map[string]int)
+		for _, term := range Terms(post.Title + "\n" + post.PlainText()) {
+			counts[i][term]++
+		}
+	}
+
+	docFreq := make(map[string]int)
+	for _, i := range candidates {
+		for term := range counts[i] {
+			docFreq[term]++
+		}
+	}
+
+	total := float64(len(candidates))
+	vectors := make([][]termWeight, len(posts))
+	for i := range posts {
+		var vector []termWeight
+		var norm float64
+		for term, count := range counts[i] {
+			df := docFreq[term]
+			if df == 0 || (total >= 4 && float64(df)/total > relatedMaxDocFreq) {
+				continue
+			}
+			weight := float64(count) * math.Log(1+total/float64(df))
+			vector = append(vector, termWeight{term, weight})
+		}
+
+		sort.Slice(vector, func(a, c int) bool {
+			if vector[a].weight != vector[c].weight {
+				return vector[a].weight > vector[c].weight
+			}
+			return vector[a].term < vector[c].term
+		})
+		if len(vector) > relatedMaxTerms {
+			vector = vector[:relatedMaxTerms]
+		}
+
+		for _, tw := range vector {
+			norm += tw.weight * tw.weight
+		}
+		norm = math.Sqrt(norm)
+		for k := range vector {
+			vector[k].weight /= norm
+		}
+		vectors[i] = vector
+	}
+
+	return vectors
+}
+
+// normalizedTags returns the tags lowercased for comparison, without duplicates.
+func normalizedTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,109 @@
+package blog
+
+import (
+	"sort"
+)
+
+// Series is an ordered group of posts sharing the same Series frontmatter key.
+type Series struct {
+	Name  string
+	Slug  string
+	Parts []PostRef // Ordered by SeriesPart, then by creation date
+}
+
+// SeriesNav places a post within its series.
+type SeriesNav struct {
+	Series *Series
+	Index  int      // Position of the post in Series.Parts
+	Prev   *PostRef // Previous part, nil for the first part
+	Next   *PostRef // Next part, nil for the last part
+
+	part int
+}
+
+// Part returns the part number of the post within its series: its
+// SeriesPart, or for unnumbered parts the number following the previous
+// part.
+func (n SeriesNav) Part() int {
+	return n.part
+}
+
+// groupSeries collects the published posts into series, ordered by name,
+// and sets SeriesNav on every post that belongs to one. Parts without a
+// SeriesPart follow the numbered ones in creation order.
+func (b *Blog) groupSeries() {
+	bySlug := make(map[string][]int)
+	names := make(map[string]string)
+	for i, post := range b.Posts {
+		b.Posts[i].SeriesNav = nil
+		if post.Series == "" || !post.Published {
+			continue
+		}
+		slug := createSlug(post.Series)
+		bySlug[slug] = append(bySlug[slug], i)
+		if _, ok := names[slug]; !ok {
+			names[slug] = post.Series
+		}
+	}
+
+	b.Series = make([]*Series, 0, len(bySlug))
+	for slug, indexes := range bySlug {
+		sort.SliceStable(indexes, func(x, y int) bool {
+			px, py := b.Posts[indexes[x]], b.Posts[indexes[y]]
+			switch {
+			case px.SeriesPart != py.SeriesPart && px.SeriesPart != 0 && py.SeriesPart != 0:
+				return px.SeriesPart < py.SeriesPart
+			case px.SeriesPart != py.SeriesPart:
+				// Numbered parts come before unnumbered ones
+				return py.SeriesPart == 0
+			}
+			return px.CreatedDate.Before(py.CreatedDate)
+		})
+
+		series := &Series{Name: names[slug], Slug: slug}
+		for _, i := range indexes {
+			series.Parts = append(series.Parts, b.Posts[i].Ref())
+		}
+
+		part := 0
+		for pos, i := range indexes {
+			part++
+			if n := b.Posts[i].SeriesPart; n != 0 {
+				part = n
+			}
+			nav := &SeriesNav{Series: series, Index: pos, part: part}
+			if pos > 0 {
+				nav.Prev = &series.Parts[pos-1]
+			}
+			if pos < len(series.Parts)-1 {
+				nav.Next = &series.Parts[pos+1]
+			}
+			b.Posts[i].SeriesNav = nav
+		}
+
+		b.Series = append(b.Series, series)
+	}
+
+	sort.Slice(b.Series, func(i, j int) bool {
+		return b.Series[i].Name < b.Series[j].Name
+	})
+}
+
+// PostsInSeries returns the posts of the series with the given slug, in part order.
+func (b *Blog) PostsInSeries(slug string) []Post {
+	var posts []Post
+	for _, series := range b.Series {
+		if series.Slug != slug {
+			continue
+		}
+		for _, part := range series.Parts {
+			for _, post := range b.Posts {
+				if post.Slug == part.Slug {
+					posts = append(posts, post)
+					break
+				}
+			}
+		}
+	}
+	return posts
+}
@@ -0,0 +1,117 @@
+package blog
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// StopWords are common English words that carry no meaning for search and
+// similarity and are dropped by Terms.
+var StopWords = []string{
+	"a", "about", "after", "all", "also", "am", "an", "and", "any", "are", "as", "at",
+	"be", "because", "been", "before", "being", "but", "by", "can", "could", "did",
+	"do", "does", "doing", "for", "from", "had", "has", "have", "having", "he", "her",
+	"here", "hers", "him", "his", "how", "i", "if", "in", "into", "is", "it", "its",
+	"just", "me", "more", "most", "my", "no", "nor", "not", "now", "of", "on", "once",
+	"only", "or", "other", "our", "ours", "out", "over", "own", "same", "she", "should",
+	"so", "some", "such", "than", "that", "the", "their", "theirs", "them", "then",
+	"there", "these", "they", "this", "those", "through", "to", "too", "under", "until",
+	"up", "very", "was", "we", "were", "what", "when", "where", "which", "while", "who",
+	"whom", "why", "will", "with", "would", "you", "your", "yours",
+}
+
+// stopWords is StopWords as a set.
+var stopWords = func() map[string]bool {
+	set := make(map[string]bool, len(StopWords))
+	for _, w := range StopWords {
+		set[w] = true
+	}
+	return set
+}()
+
+// stemRules are the suffix rewrites applied by Stem, tried in order. The first
+// rule whose suffix matches and leaves a stem of at least minStem letters wins.
+// The search script in the default theme mirrors these rules; keep both in sync.
+var stemRules = []struct {
+	suffix, replacement string
+	minStem             int
+}{
+	{"ational", "ate", 2},
+	{"tional", "tion", 2},
+	{"iveness", "ive", 2},
+	{"fulness", "ful", 2},
+	{"ousness", "ous", 2},
+	{"ization", "ize", 2},
+	{"ation", "ate", 3},
+	{"ement", "", 4},
+	{"ment", "", 4},
+	{"ness", "", 3},
+	{"ings", "", 3},
+	{"ing", "", 3},
+	{"edly", "", 3},
+	{"ied", "y", 2},
+	{"ies", "y", 2},
+	{"sses", "ss", 2},
+	{"ed", "", 3},
+	{"ly", "", 3},
+	{"ss", "ss", 2},
+	{"us", "us", 2},
+	{"is", "is", 2},
+	{"s", "", 3},
+}
+
+// Stem reduces an English word to its stem with a small set of suffix rules,
+// so that "posts", "posting" and "posted" all become "post".
+func Stem(word string) string {
+	for _, rule := range stemRules {
+		if strings.HasSuffix(word, rule.suffix) && utf8.RuneCountInString(word)-len(rule.suffix) >= rule.minStem {
+			return word[:len(word)-len(rule.suffix)] + rule.replacement
+		}
+	}
+	return word
+}
+
+// Terms splits text into lowercase words, drops stop words and single
+// letters, and returns the stems of the remaining words in order.
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if utf8.RuneCountInString(w) < 2 || stopWords[w] {
+			continue
+		}
+		terms = append(terms, Stem(w))
+	}
+	return terms
+}
+
+var (
+	// tagPattern matches HTML tags
+	tagPattern = regexp.MustCompile(`<[^>]*>`)
+	// headingPattern matches h2 to h6 headings in rendered HTML
+	headingPattern = regexp.MustCompile(`(?s)<h[2-6][^>]*>(.*?)</h[2-6]>`)
+)
+
+// PlainText returns the text of the post without markup.
+func (p Post) PlainText() string {
+	text := tagPattern.ReplaceAllString(string(p.ContentHTML), " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// Headings returns the text of the section headings in the post.
+func (p Post) Headings() []string {
+	var headings []string
+	for _, m := range headingPattern.FindAllStringSubmatch(string(p.ContentHTML), -1) {
+		text := html.UnescapeString(tagPattern.ReplaceAllString(m[1], ""))
+		if text = strings.TrimSpace(text); text != "" {
+			headings = append(headings, text)
+		}
+	}
+	return headings
+}
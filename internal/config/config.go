@@ -0,0 +1,454 @@
+// Package config provides loading of the musings site configuration.
+//
+// The configuration is read from a YAML file (musing.yaml by default) in the
+// project root. Every setting has a sensible default so the file is optional;
+// values present in the file override the defaults.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the configuration file used when none is specified.
+const DefaultPath = "musing.yaml"
+
+// Config holds the configuration for a musings site.
+type Config struct {
+	Site SiteConfig `yaml:"site"`
+
+	// TimeZone is the IANA name of the site time zone, e.g. "Europe/Berlin".
+	// Dates without an offset are interpreted in it and pages and feeds
+	// render dates in it.
+	TimeZone string `yaml:"timezone"`
+
+	Dates   DatesConfig   `yaml:"dates"`
+	Authors AuthorsConfig `yaml:"authors"`
+	Feeds   FeedsConfig   `yaml:"feeds"`
+	Podcast PodcastConfig `yaml:"podcast"`
+	Related RelatedConfig `yaml:"related"`
+	Nav     NavConfig     `yaml:"navigation"`
+	Cards   CardsConfig   `yaml:"cards"`
+	Images  ImagesConfig  `yaml:"images"`
+	Assets  AssetsConfig  `yaml:"assets"`
+	Output  OutputConfig  `yaml:"output"`
+	Hosting HostingConfig `yaml:"hosting"`
+	Deploy  DeployConfig  `yaml:"deploy"`
+	Infra   InfraConfig   `yaml:"infra"`
+	Sync    SyncConfig    `yaml:"sync"`
+}
+
+// HostingConfig holds the settings for how the published files are served.
+// Publish turns them, together with the aliases of posts, into redirect and
+// header rules for the hosts listed in Formats.
+type HostingConfig struct {
+	CacheControl CacheControlConfig `yaml:"cache_control"`
+
+	// Headers are sent with every response, such as security headers. Set a
+	// default header to an empty value to leave it out.
+	Headers map[string]string `yaml:"headers"`
+
+	// Formats lists the rule files to generate: netlify writes _redirects
+	// and _headers to the output directory, as read by Netlify and
+	// Cloudflare Pages; nginx and cloudfront write an nginx include and a
+	// CloudFront Function to Dir.
+	Formats []string `yaml:"formats"`
+
+	Dir string `yaml:"dir"` // Directory for rule files that are not part of the site
+}
+
+// CacheControlConfig holds the Cache-Control header values by kind of file.
+type CacheControlConfig struct {
+	Pages   string `yaml:"pages"`   // HTML pages, feeds and other files that change in place
+	Assets  string `yaml:"assets"`  // Fingerprinted style sheets and scripts
+	Default string `yaml:"default"` // Everything else, such as images and fonts
+}
+
+// SyncConfig holds the settings for syncing posts to external platforms.
+// Posts choose their platforms with the Syndicate frontmatter key; access
+// tokens are read from the environment.
+type SyncConfig struct {
+	Draft  bool         `yaml:"draft"`  // Create posts as drafts instead of publishing them
+	Delete bool         `yaml:"delete"` // Remove remote posts whose local post no longer syndicates to the platform
+	State  string       `yaml:"state"`  // File recording the copy of every post on each platform
+	DevTo  DevToConfig  `yaml:"devto"`
+	Medium MediumConfig `yaml:"medium"`
+}
+
+// DevToConfig holds the settings for DEV (dev.to). The API key is read from
+// the DEVTO_API_KEY environment variable.
+type DevToConfig struct {
+	URL string `yaml:"url"` // API base URL
+}
+
+// MediumConfig holds the settings for Medium. The integration token is read
+// from the MEDIUM_TOKEN environment variable.
+type MediumConfig struct {
+	URL     string `yaml:"url"`      // API base URL
+	FeedURL string `yaml:"feed_url"` // Base URL of the profile feeds, which list published posts
+}
+
+// InfraConfig holds the settings for generating the infrastructure that
+// hosts the site. The domain is taken from site.base_url and the bucket from
+// deploy.s3.
+type InfraConfig struct {
+	Dir        string `yaml:"dir"`         // Directory the Terraform module is written to
+	Zone       string `yaml:"zone"`        // Route53 hosted zone; empty uses the domain, which must then have two labels
+	PriceClass string `yaml:"price_class"` // CloudFront price class: PriceClass_100, PriceClass_200 or PriceClass_All
+}
+
+// DeployConfig holds the settings for deploying the output directory.
+type DeployConfig struct {
+	Target string     `yaml:"target"` // Deploy target used when none is given on the command line
+	S3     S3Config   `yaml:"s3"`
+	Git    GitConfig  `yaml:"git"`
+	SFTP   SFTPConfig `yaml:"sftp"`
+}
+
+// GitConfig holds the settings for deploying to a branch of a git
+// repository, as served by GitHub Pages and similar hosts.
+type GitConfig struct {
+	Repo     string `yaml:"repo"`     // Path of the local repository
+	Branch   string `yaml:"branch"`   // Branch the site is committed to
+	Orphan   bool   `yaml:"orphan"`   // Replace the branch with a single commit on every deploy
+	CNAME    string `yaml:"cname"`    // Custom domain written to a CNAME file, if set
+	NoJekyll bool   `yaml:"nojekyll"` // Add a .nojekyll file so GitHub Pages serves the files as they are
+}
+
+// SFTPConfig holds the settings for deploying to a directory on a server
+// reachable over SSH. Keys are taken from the SSH agent and the key file, and
+// the server must be listed in the known hosts file.
+type SFTPConfig struct {
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	User       string `yaml:"user"`        // Empty uses the local user name
+	KeyFile    string `yaml:"key_file"`    // Private key; empty tries ~/.ssh/id_ed25519, id_ecdsa and id_rsa
+	KnownHosts string `yaml:"known_hosts"` // Empty uses ~/.ssh/known_hosts
+	Dir        string `yaml:"dir"`         // Remote directory the site is served from
+	Delete     bool   `yaml:"delete"`      // Delete remote files removed from the site
+	FileMode   string `yaml:"file_mode"`   // Octal permissions of uploaded files
+	DirMode    string `yaml:"dir_mode"`    // Octal permissions of created directories
+}
+
+// Modes returns the permissions of uploaded files and created directories.
+func (c SFTPConfig) Modes() (file, dir os.FileMode, err error) {
+	f, err := strconv.ParseUint(c.FileMode, 8, 32)
+	if err != nil || f > 0777 {
+		return 0, 0, fmt.Errorf("deploy.sftp.file_mode must be octal permissions such as 0644, got %q", c.FileMode)
+	}
+	d, err := strconv.ParseUint(c.DirMode, 8, 32)
+	if err != nil || d > 0777 {
+		return 0, 0, fmt.Errorf("deploy.sftp.dir_mode must be octal permissions such as 0755, got %q", c.DirMode)
+	}
+	return os.FileMode(f), os.FileMode(d), nil
+}
+
+// S3Config holds the settings for deploying to S3-compatible storage.
+// Credentials are taken from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables or the shared AWS credentials file.
+type S3Config struct {
+	Bucket    string `yaml:"bucket"`
+	Region    string `yaml:"region"`     // Empty uses AWS_REGION, falling back to us-east-1
+	Endpoint  string `yaml:"endpoint"`   // Service URL, e.g. http://localhost:9000 for MinIO; empty for AWS
+	PathStyle bool   `yaml:"path_style"` // Address the bucket in the path rather than the host name
+	Prefix    string `yaml:"prefix"`     // Key prefix the site is stored under
+	Delete    bool   `yaml:"delete"`     // Delete objects for files removed from the site
+}
+
+// OutputConfig holds the settings for the output directory.
+type OutputConfig struct {
+	// Protect lists paths in the output directory that the generator never
+	// removes, such as CNAME. Entries are path.Match patterns relative to
+	// the output directory; a matching directory protects its contents.
+	Protect []string `yaml:"protect"`
+}
+
+// AssetsConfig holds the settings for the asset pipeline.
+type AssetsConfig struct {
+	Minify      bool `yaml:"minify"`      // Minify HTML pages and theme CSS/JS
+	Fingerprint bool `yaml:"fingerprint"` // Put a content hash into CSS/JS file names
+}
+
+// CardsConfig holds the settings for the generated social preview images of
+// posts without their own Image.
+type CardsConfig struct {
+	Enabled         bool   `yaml:"enabled"`          // Whether cards are generated
+	Background      string `yaml:"background"`       // Background color as #rrggbb
+	BackgroundImage string `yaml:"background_image"` // Optional image scaled to cover the card
+	TextColor       string `yaml:"text_color"`       // Text color as #rrggbb
+	Font            string `yaml:"font"`             // TrueType/OpenType font file; empty uses the Go fonts
+	CacheDir        string `yaml:"cache_dir"`        // Directory holding drawn cards between builds
+}
+
+// NavConfig holds the settings for previous/next post links.
+type NavConfig struct {
+	// Within limits previous/next links to posts in the same "section"
+	// (top-level directory below the posts directory) or sharing the post's
+	// first "tag". Empty links all published posts.
+	Within string `yaml:"within"`
+}
+
+// RelatedConfig holds the settings for related post suggestions.
+type RelatedConfig struct {
+	Count int `yaml:"count"` // Number of related posts shown per post; 0 disables them
+}
+
+// PodcastConfig holds the settings for the podcast feed, generated when at
+// least one post has an audio attachment. Empty values fall back to the site
+// settings.
+type PodcastConfig struct {
+	Title       string `yaml:"title"`       // Podcast name
+	Description string `yaml:"description"` // Podcast summary
+	Author      string `yaml:"author"`      // Name shown as the podcast author
+	OwnerName   string `yaml:"owner_name"`  // Contact name for podcast directories
+	OwnerEmail  string `yaml:"owner_email"` // Contact address for podcast directories
+	Image       string `yaml:"image"`       // Artwork path relative to the site root, or a URL
+	Category    string `yaml:"category"`    // Apple Podcasts category, e.g. Technology
+	Explicit    bool   `yaml:"explicit"`    // Whether the podcast contains explicit content
+}
+
+// FeedsConfig holds the settings for the RSS, Atom and JSON feeds.
+type FeedsConfig struct {
+	// Content selects what feed entries carry: "full" for the complete post
+	// HTML alongside the summary, or "summary" for the summary only.
+	Content string `yaml:"content"`
+
+	// Limit caps the number of entries in each feed; 0 means no limit.
+	Limit int `yaml:"limit"`
+}
+
+// FullContent reports whether feeds carry the complete post HTML.
+func (f FeedsConfig) FullContent() bool {
+	return f.Content != "summary"
+}
+
+// SiteConfig holds the general settings of the site.
+type SiteConfig struct {
+	Title       string `yaml:"title"`       // Site name used in pages and feeds
+	Description string `yaml:"description"` // Short description used in feeds
+	BaseURL     string `yaml:"base_url"`    // Absolute URL the site is served from, without trailing slash
+	Language    string `yaml:"language"`    // Language code, e.g. en-us
+	Image       string `yaml:"image"`       // Default social preview image, relative to the site root or a URL
+	Twitter     string `yaml:"twitter"`     // Twitter handle of the site including the @
+}
+
+// AuthorsConfig holds the settings for post authors.
+type AuthorsConfig struct {
+	File    string `yaml:"file"`    // YAML file with author profiles keyed by author ID
+	Default string `yaml:"default"` // Author ID for posts without an Author frontmatter key
+}
+
+// DatesConfig holds the settings for deriving post dates.
+type DatesConfig struct {
+	// FromGit fills missing CreatedDate/UpdatedDate values from the first and
+	// last commit of each post. Explicit frontmatter dates always win.
+	FromGit bool `yaml:"from_git"`
+}
+
+// ImagesConfig holds the settings for the build-time image pipeline.
+type ImagesConfig struct {
+	Widths      []int  `yaml:"widths"`       // Widths in pixels of the resized variants
+	Sizes       string `yaml:"sizes"`        // Value of the sizes attribute on rendered images
+	JPEGQuality int    `yaml:"jpeg_quality"` // JPEG encoding quality (1-100)
+	PNGLevel    string `yaml:"png_level"`    // PNG compression: default, none, speed or best
+	CacheDir    string `yaml:"cache_dir"`    // Directory holding processed variants between builds
+}
+
+// Default returns the configuration used when no configuration file exists.
+func Default() *Config {
+	return &Config{
+		Site: SiteConfig{
+			Title:       "My Blog",
+			Description: "A blog about technology and programming",
+			BaseURL:     "http://localhost:8080",
+			Language:    "en-us",
+		},
+		TimeZone: "UTC",
+		Authors: AuthorsConfig{
+			File: "authors.yaml",
+		},
+		Cards: CardsConfig{
+			Enabled:    true,
+			Background: "#333333",
+			TextColor:  "#ffffff",
+			CacheDir:   ".musing/cache/cards",
+		},
+		Related: RelatedConfig{
+			Count: 3,
+		},
+		Feeds: FeedsConfig{
+			Content: "full",
+			Limit:   20,
+		},
+		Images: ImagesConfig{
+			Widths:      []int{480, 800, 1200},
+			Sizes:       "(max-width: 800px) 100vw, 800px",
+			JPEGQuality: 80,
+			PNGLevel:    "best",
+			CacheDir:    ".musing/cache/images",
+		},
+		Assets: AssetsConfig{
+			Minify:      true,
+			Fingerprint: true,
+		},
+		Hosting: HostingConfig{
+			CacheControl: CacheControlConfig{
+				Pages:   "public, max-age=0, must-revalidate",
+				Assets:  "public, max-age=31536000, immutable",
+				Default: "public, max-age=86400",
+			},
+			Headers: map[string]string{
+				"X-Content-Type-Options": "nosniff",
+				"X-Frame-Options":        "SAMEORIGIN",
+				"Referrer-Policy":        "strict-origin-when-cross-origin",
+				"Permissions-Policy":     "camera=(), microphone=(), geolocation=()",
+			},
+			Formats: []string{"netlify", "nginx", "cloudfront"},
+			Dir:     ".musing/hosting",
+		},
+		Deploy: DeployConfig{
+			Target: "s3",
+			S3: S3Config{
+				Delete: true,
+			},
+			Git: GitConfig{
+				Repo:     ".",
+				Branch:   "gh-pages",
+				NoJekyll: true,
+			},
+			SFTP: SFTPConfig{
+				Port:     22,
+				Delete:   true,
+				FileMode: "0644",
+				DirMode:  "0755",
+			},
+		},
+		Sync: SyncConfig{
+			State:  ".musing/sync.json",
+			DevTo:  DevToConfig{URL: "https://dev.to"},
+			Medium: MediumConfig{URL: "https://api.medium.com", FeedURL: "https://medium.com"},
+		},
+		Infra: InfraConfig{
+			Dir:        "infrastructure/terraform",
+			PriceClass: "PriceClass_100",
+		},
+		Output: OutputConfig{
+			Protect: []string{"CNAME", ".nojekyll", ".well-known"},
+		},
+	}
+}
+
+// Load reads the configuration file at path on top of the defaults.
+// A missing file is not an error; the defaults are returned instead.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	cfg.Site.BaseURL = strings.TrimSuffix(cfg.Site.BaseURL, "/")
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Location returns the site time zone, falling back to UTC if it cannot be loaded.
+func (c *Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// validate checks the configuration for values that cannot be used.
+func (c *Config) validate() error {
+	if u, err := url.Parse(c.Site.BaseURL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("site.base_url must be an absolute URL, got %q", c.Site.BaseURL)
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	if c.Feeds.Content != "full" && c.Feeds.Content != "summary" {
+		return fmt.Errorf("feeds.content must be full or summary, got %q", c.Feeds.Content)
+	}
+	if c.Feeds.Limit < 0 {
+		return fmt.Errorf("feeds.limit must not be negative, got %d", c.Feeds.Limit)
+	}
+	switch c.Nav.Within {
+	case "", "section", "tag":
+	default:
+		return fmt.Errorf("navigation.within must be empty, section or tag, got %q", c.Nav.Within)
+	}
+	if c.Related.Count < 0 {
+		return fmt.Errorf("related.count must not be negative, got %d", c.Related.Count)
+	}
+	for _, w := range c.Images.Widths {
+		if w <= 0 {
+			return fmt.Errorf("images.widths must be positive, got %d", w)
+		}
+	}
+	if c.Images.JPEGQuality < 1 || c.Images.JPEGQuality > 100 {
+		return fmt.Errorf("images.jpeg_quality must be between 1 and 100, got %d", c.Images.JPEGQuality)
+	}
+	switch c.Images.PNGLevel {
+	case "default", "none", "speed", "best":
+	default:
+		return fmt.Errorf("images.png_level must be one of default, none, speed or best, got %q", c.Images.PNGLevel)
+	}
+	switch c.Deploy.Target {
+	case "s3", "git", "sftp":
+	default:
+		return fmt.Errorf("deploy.target must be s3, git or sftp, got %q", c.Deploy.Target)
+	}
+	if c.Deploy.SFTP.Port < 1 || c.Deploy.SFTP.Port > 65535 {
+		return fmt.Errorf("deploy.sftp.port must be between 1 and 65535, got %d", c.Deploy.SFTP.Port)
+	}
+	if _, _, err := c.Deploy.SFTP.Modes(); err != nil {
+		return err
+	}
+	switch c.Infra.PriceClass {
+	case "PriceClass_100", "PriceClass_200", "PriceClass_All":
+	default:
+		return fmt.Errorf("infra.price_class must be PriceClass_100, PriceClass_200 or PriceClass_All, got %q", c.Infra.PriceClass)
+	}
+	for _, format := range c.Hosting.Formats {
+		switch format {
+		case "netlify", "nginx", "cloudfront":
+		default:
+			return fmt.Errorf("hosting.formats must contain netlify, nginx or cloudfront, got %q", format)
+		}
+	}
+	for name, value := range c.Hosting.Headers {
+		if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+			return fmt.Errorf("hosting.headers: invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("hosting.headers: value of %s must be a single line", name)
+		}
+	}
+	for _, pattern := range c.Output.Protect {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("output.protect: invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
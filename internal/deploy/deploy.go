@@ -0,0 +1,135 @@
+// Package deploy copies a published site to where it is served from.
+//
+// Deploys are driven by the site manifest: the manifest of the local build is
+// compared with the one stored at the target by the previous deploy, and only
+// added and changed files are transferred. The new manifest is stored last,
+// so an interrupted deploy is simply completed by the next one.
+package deploy
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/manifest"
+)
+
+// Target is a place a site can be deployed to.
+type Target interface {
+	// Manifest returns the manifest stored by the previous deploy, or an
+	// empty manifest if the site has not been deployed yet.
+	Manifest() (*manifest.Manifest, error)
+
+	// Upload copies the file described by e from the output directory dir.
+	Upload(dir string, e manifest.Entry) error
+
+	// Delete removes the file at path, relative to the site root.
+	Delete(path string) error
+
+	// Finish completes the deploy and stores m as the deployed manifest.
+	Finish(m *manifest.Manifest) error
+}
+
+// Options controls a deploy.
+type Options struct {
+	DryRun bool // Only print the plan
+	Delete bool // Remove files that are no longer part of the site
+}
+
+// Plan lists the changes a deploy makes.
+type Plan struct {
+	Upload []manifest.Entry
+	Delete []manifest.Entry
+}
+
+// Empty reports whether the plan changes nothing.
+func (p Plan) Empty() bool {
+	return len(p.Upload) == 0 && len(p.Delete) == 0
+}
+
+// Deploy brings the target up to date with the site in dir, which must have
+// been published with a manifest. Pages are uploaded after the files they
+// refer to, and removed files are deleted last.
+func Deploy(t Target, dir string, opts Options) (Plan, error) {
+	local, err := manifest.Load(filepath.Join(dir, manifest.FileName))
+	if err != nil {
+		return Plan{}, fmt.Errorf("error loading manifest (run 'musings publish' first): %w", err)
+	}
+	remote, err := t.Manifest()
+	if err != nil {
+		return Plan{}, fmt.Errorf("error loading deployed manifest: %w", err)
+	}
+
+	diff := manifest.Compare(remote, local)
+	plan := Plan{Upload: append(diff.Added, diff.Changed...)}
+	if opts.Delete {
+		plan.Delete = diff.Removed
+	}
+	sort.SliceStable(plan.Upload, func(i, j int) bool {
+		return !isPage(plan.Upload[i]) && isPage(plan.Upload[j])
+	})
+
+	if opts.DryRun {
+		for _, e := range plan.Upload {
+			fmt.Printf("Would upload: %s\n", e.Path)
+		}
+		for _, e := range plan.Delete {
+			fmt.Printf("Would delete: %s\n", e.Path)
+		}
+		return plan, nil
+	}
+
+	for _, e := range plan.Upload {
+		if err := t.Upload(dir, e); err != nil {
+			return plan, fmt.Errorf("error uploading %s: %w", e.Path, err)
+		}
+		fmt.Printf("Uploaded: %s\n", e.Path)
+	}
+	for _, e := range plan.Delete {
+		if err := t.Delete(e.Path); err != nil {
+			return plan, fmt.Errorf("error deleting %s: %w", e.Path, err)
+		}
+		fmt.Printf("Deleted: %s\n", e.Path)
+	}
+
+	if plan.Empty() {
+		return plan, nil
+	}
+
+	// Files that were not deleted are still at the target, so they stay in
+	// the deployed manifest for a later deploy with delete enabled
+	deployed := local
+	if kept := len(diff.Removed) - len(plan.Delete); kept > 0 {
+		deployed = &manifest.Manifest{Files: append(slices.Clone(local.Files), diff.Removed...)}
+		sort.Slice(deployed.Files, func(i, j int) bool { return deployed.Files[i].Path < deployed.Files[j].Path })
+	}
+	if err := t.Finish(deployed); err != nil {
+		return plan, fmt.Errorf("error storing manifest: %w", err)
+	}
+	return plan, nil
+}
+
+// isPage reports whether e is an HTML page.
+func isPage(e manifest.Entry) bool {
+	return strings.HasPrefix(e.ContentType, "text/html")
+}
+
+// fingerprinted matches the names of style sheets and scripts carrying a
+// content hash, which never change under the same name.
+var fingerprinted = regexp.MustCompile(`\.[0-9a-f]{8}\.(css|js)$`)
+
+// CacheControl returns the Cache-Control header value for the file at path.
+func CacheControl(cc config.CacheControlConfig, path string) string {
+	if fingerprinted.MatchString(path) {
+		return cc.Assets
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case "", ".html", ".xml", ".json", ".txt":
+		return cc.Pages
+	}
+	return cc.Default
+}
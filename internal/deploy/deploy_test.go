@@ -0,0 +1,93 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m4xw311/musing/internal/manifest"
+)
+
+// publishSite writes files and their manifest to a temporary output
+// directory, as 'musings publish' does.
+func publishSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, m := writeSite(t, files)
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifest.FileName), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func paths(entries []manifest.Entry) []string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Path)
+	}
+	return names
+}
+
+func TestDeployKeepsUndeletedFiles(t *testing.T) {
+	s, fake := newTestS3(t, "")
+	first := publishSite(t, map[string]string{"index.html": "one", "old.html": "old"})
+	if _, err := Deploy(s, first, Options{Delete: true}); err != nil {
+		t.Fatalf("first deploy: %v", err)
+	}
+
+	second := publishSite(t, map[string]string{"index.html": "two"})
+	plan, err := Deploy(s, second, Options{})
+	if err != nil {
+		t.Fatalf("deploy without delete: %v", err)
+	}
+	if got := paths(plan.Upload); len(got) != 1 || got[0] != "index.html" {
+		t.Errorf("uploaded %v", got)
+	}
+	if len(plan.Delete) != 0 {
+		t.Errorf("deleted %v without delete enabled", paths(plan.Delete))
+	}
+	if _, ok := fake.objects["old.html"]; !ok {
+		t.Fatal("old.html was removed from the bucket")
+	}
+	stored, err := s.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Lookup("old.html"); !ok {
+		t.Errorf("deployed manifest lost old.html: %v", paths(stored.Files))
+	}
+
+	// A later deploy with delete enabled still knows about the old file
+	plan, err = Deploy(s, second, Options{Delete: true})
+	if err != nil {
+		t.Fatalf("deploy with delete: %v", err)
+	}
+	if got := paths(plan.Delete); len(got) != 1 || got[0] != "old.html" {
+		t.Errorf("deleted %v, want [old.html]", got)
+	}
+	if _, ok := fake.objects["old.html"]; ok {
+		t.Error("old.html is still in the bucket")
+	}
+	stored, err = s.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored.Lookup("old.html"); ok {
+		t.Error("deployed manifest still lists old.html")
+	}
+}
+
+func TestDeployDryRun(t *testing.T) {
+	s, fake := newTestS3(t, "")
+	dir := publishSite(t, map[string]string{"index.html": "one"})
+	plan, err := Deploy(s, dir, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Upload) != 1 || len(fake.objects) != 0 {
+		t.Errorf("dry run planned %v and stored %d objects", paths(plan.Upload), len(fake.objects))
+	}
+}
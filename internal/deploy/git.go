@@ -0,0 +1,239 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/manifest"
+)
+
+// Git deploys by committing the site to a branch of a local git repository.
+// The commit is built with a temporary index and plumbing commands, so the
+// working tree, the index and HEAD of the repository are left untouched.
+type Git struct {
+	Config config.GitConfig
+
+	// Titles maps the pages of posts to their titles for the commit message.
+	Titles map[string]string
+
+	parent  string   // Commit the branch points to, empty if it does not exist
+	base    string   // Commit whose tree the new one starts from, empty for none
+	uploads []string // Files to add, as paths on disk
+	paths   []string // Site paths of uploads, in the same order
+	deletes []string // Site paths to remove
+}
+
+// NewGit creates a git target from the configuration.
+func NewGit(cfg config.GitConfig, titles map[string]string) (*Git, error) {
+	if cfg.Branch == "" {
+		return nil, errors.New("deploy.git.branch is not set")
+	}
+	g := &Git{Config: cfg, Titles: titles}
+	if _, err := g.git(nil, nil, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("deploy.git.repo: %w", err)
+	}
+	return g, nil
+}
+
+// Manifest implements Target. It reads the manifest committed by the
+// previous deploy. A branch without one, for example one created by other
+// tools, is treated as empty, so its whole content is replaced.
+func (g *Git) Manifest() (*manifest.Manifest, error) {
+	out, err := g.git(nil, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+g.Config.Branch)
+	if err != nil {
+		return &manifest.Manifest{}, nil
+	}
+	g.parent = strings.TrimSpace(string(out))
+
+	data, err := g.git(nil, nil, "show", g.parent+":"+manifest.FileName)
+	if err != nil {
+		return &manifest.Manifest{}, nil
+	}
+	g.base = g.parent
+	return manifest.Parse(data)
+}
+
+// Upload implements Target. Files are added to the repository when the
+// deploy finishes.
+func (g *Git) Upload(dir string, e manifest.Entry) error {
+	path, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return err
+	}
+	g.uploads = append(g.uploads, path)
+	g.paths = append(g.paths, e.Path)
+	return nil
+}
+
+// Delete implements Target. Files are removed when the deploy finishes.
+func (g *Git) Delete(path string) error {
+	g.deletes = append(g.deletes, path)
+	return nil
+}
+
+// Finish implements Target by committing the new tree to the branch.
+func (g *Git) Finish(m *manifest.Manifest) error {
+	tmp, err := os.MkdirTemp("", "musings-deploy-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmp, "index")}
+
+	// Start from the previously deployed tree
+	if g.base != "" {
+		if _, err := g.git(env, nil, "read-tree", g.base); err != nil {
+			return err
+		}
+	} else {
+		if _, err := g.git(env, nil, "read-tree", "--empty"); err != nil {
+			return err
+		}
+	}
+
+	// Store the new and changed files as blobs
+	var info strings.Builder
+	if len(g.uploads) > 0 {
+		out, err := g.git(nil, strings.NewReader(strings.Join(g.uploads, "\n")+"\n"), "hash-object", "-w", "--stdin-paths")
+		if err != nil {
+			return err
+		}
+		blobs := strings.Fields(string(out))
+		if len(blobs) != len(g.uploads) {
+			return fmt.Errorf("git hash-object returned %d objects for %d files", len(blobs), len(g.uploads))
+		}
+		for i, blob := range blobs {
+			fmt.Fprintf(&info, "100644 %s\t%s\n", blob, g.paths[i])
+		}
+	}
+	for _, path := range g.deletes {
+		fmt.Fprintf(&info, "0 0000000000000000000000000000000000000000\t%s\n", path)
+	}
+
+	// Add the manifest and the files the host needs
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	extra := map[string][]byte{manifest.FileName: data}
+	if g.Config.NoJekyll {
+		extra[".nojekyll"] = nil
+	}
+	if g.Config.CNAME != "" {
+		extra["CNAME"] = []byte(g.Config.CNAME + "\n")
+	}
+	for path, content := range extra {
+		out, err := g.git(nil, bytes.NewReader(content), "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&info, "100644 %s\t%s\n", strings.TrimSpace(string(out)), path)
+	}
+
+	if _, err := g.git(env, strings.NewReader(info.String()), "update-index", "--index-info"); err != nil {
+		return err
+	}
+	out, err := g.git(env, nil, "write-tree")
+	if err != nil {
+		return err
+	}
+	tree := strings.TrimSpace(string(out))
+
+	// Commit on top of the branch, or as a new root commit
+	args := []string{"commit-tree", tree, "-F", "-"}
+	if g.parent != "" && !g.Config.Orphan {
+		args = append(args, "-p", g.parent)
+	}
+	out, err = g.git(nil, strings.NewReader(g.commitMessage()), args...)
+	if err != nil {
+		return err
+	}
+	commit := strings.TrimSpace(string(out))
+
+	// Move the branch only if nobody else moved it in the meantime
+	update := []string{"update-ref", "refs/heads/" + g.Config.Branch, commit}
+	if g.parent != "" {
+		update = append(update, g.parent)
+	}
+	if _, err := g.git(nil, nil, update...); err != nil {
+		return err
+	}
+
+	fmt.Printf("Committed %s to branch %s\n", commit[:min(len(commit), 12)], g.Config.Branch)
+	return nil
+}
+
+// commitMessage describes the deploy, listing the posts it adds, updates and
+// removes.
+func (g *Git) commitMessage() string {
+	var added, updated, removed []string
+	previous := make(map[string]bool)
+	if g.base != "" {
+		if out, err := g.git(nil, nil, "ls-tree", "-r", "--name-only", g.base); err == nil {
+			for _, path := range strings.Split(string(out), "\n") {
+				previous[path] = true
+			}
+		}
+	}
+	for _, path := range g.paths {
+		title, ok := g.Titles[path]
+		if !ok {
+			continue
+		}
+		if previous[path] {
+			updated = append(updated, fmt.Sprintf("%s (%s)", title, path))
+		} else {
+			added = append(added, fmt.Sprintf("%s (%s)", title, path))
+		}
+	}
+	for _, path := range g.deletes {
+		if strings.HasSuffix(path, ".html") {
+			removed = append(removed, path)
+		}
+	}
+
+	var msg strings.Builder
+	files := len(g.paths) + len(g.deletes)
+	if posts := len(added) + len(updated) + len(removed); posts > 0 {
+		fmt.Fprintf(&msg, "Deploy site: %d post(s) changed, %d file(s) in total\n", posts, files)
+	} else {
+		fmt.Fprintf(&msg, "Deploy site: %d file(s) changed\n", files)
+	}
+	for _, section := range []struct {
+		name  string
+		items []string
+	}{{"Added", added}, {"Updated", updated}, {"Removed", removed}} {
+		if len(section.items) == 0 {
+			continue
+		}
+		sort.Strings(section.items)
+		fmt.Fprintf(&msg, "\n%s:\n", section.name)
+		for _, item := range section.items {
+			fmt.Fprintf(&msg, "- %s\n", item)
+		}
+	}
+	return msg.String()
+}
+
+// git runs a git command in the repository with extra environment variables
+// and standard input, and returns its output.
+func (g *Git) git(env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.Config.Repo}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,283 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+	"github.com/m4xw311/musing/internal/manifest"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTP deploys to a directory on a server reachable over SSH, such as the
+// document root of a plain web server. Files are written under a temporary
+// name and renamed into place, so visitors never see a partial file.
+type SFTP struct {
+	Config config.SFTPConfig
+
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	conn     *ssh.Client
+	session  *ssh.Session
+	client   *sftpClient
+	dirs     map[string]bool // Remote directories known to exist
+	emptied  map[string]bool // Remote directories files were deleted from
+}
+
+// NewSFTP connects to the server in the configuration and starts an SFTP
+// session. Close must be called when the deploy is done.
+func NewSFTP(cfg config.SFTPConfig) (*SFTP, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("deploy.sftp.host is not set")
+	}
+	fileMode, dirMode, err := cfg.Modes()
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig, err := sshClientConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	conn, err := ssh.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", addr, err)
+	}
+
+	s := &SFTP{
+		Config:   cfg,
+		fileMode: fileMode,
+		dirMode:  dirMode,
+		conn:     conn,
+		dirs:     make(map[string]bool),
+		emptied:  make(map[string]bool),
+	}
+	if s.session, err = conn.NewSession(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	stdin, err := s.session.StdinPipe()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	stdout, err := s.session.StdoutPipe()
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	if err := s.session.RequestSubsystem("sftp"); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("error starting sftp on %s: %w", addr, err)
+	}
+	if s.client, err = newSFTPClient(stdout, stdin); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sshClientConfig authenticates with the keys of the SSH agent and the key
+// file, and accepts only host keys listed in the known hosts file.
+func sshClientConfig(cfg config.SFTPConfig) (*ssh.ClientConfig, error) {
+	home, _ := os.UserHomeDir()
+
+	name := cfg.User
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("deploy.sftp.user is not set: %w", err)
+		}
+		name = u.Username
+	}
+
+	knownHosts := cfg.KnownHosts
+	if knownHosts == "" {
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("error reading known hosts: %w", err)
+	}
+
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if c, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(c).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+	keyFiles := []string{cfg.KeyFile}
+	if cfg.KeyFile == "" {
+		keyFiles = []string{
+			filepath.Join(home, ".ssh", "id_ed25519"),
+			filepath.Join(home, ".ssh", "id_ecdsa"),
+			filepath.Join(home, ".ssh", "id_rsa"),
+		}
+	}
+	for _, file := range keyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if cfg.KeyFile != "" {
+				return nil, fmt.Errorf("error reading deploy.sftp.key_file: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			// Keys with a passphrase can only be used through the agent
+			if cfg.KeyFile != "" && len(signers) == 0 {
+				return nil, fmt.Errorf("error parsing %s (add passphrase-protected keys to ssh-agent): %w", file, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("no SSH keys found: start ssh-agent or set deploy.sftp.key_file")
+	}
+
+	return &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeys,
+	}, nil
+}
+
+// Close ends the SFTP session and the connection.
+func (s *SFTP) Close() error {
+	if s.session != nil {
+		s.session.Close()
+	}
+	return s.conn.Close()
+}
+
+// Manifest implements Target.
+func (s *SFTP) Manifest() (*manifest.Manifest, error) {
+	data, err := s.client.ReadFile(s.remote(manifest.FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &manifest.Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Parse(data)
+}
+
+// Upload implements Target.
+func (s *SFTP) Upload(dir string, e manifest.Entry) error {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := s.mkdirAll(path.Dir(s.remote(e.Path))); err != nil {
+		return err
+	}
+	return s.replace(s.remote(e.Path), file)
+}
+
+// Delete implements Target.
+func (s *SFTP) Delete(p string) error {
+	if err := s.client.Remove(s.remote(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.emptied[path.Dir(s.remote(p))] = true
+	return nil
+}
+
+// Finish implements Target by storing the manifest and removing the
+// directories that deleted files left empty.
+func (s *SFTP) Finish(m *manifest.Manifest) error {
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.mkdirAll(s.remote(".")); err != nil {
+		return err
+	}
+	if err := s.replace(s.remote(manifest.FileName), bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	// Remove the deepest directories first so emptied parents go too. A
+	// directory that still has files fails to be removed and is kept.
+	root := s.remote(".")
+	var dirs []string
+	for dir := range s.emptied {
+		for ; dir != root && dir != "." && dir != "/"; dir = path.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	removed := make(map[string]bool)
+	for _, dir := range dirs {
+		if removed[dir] {
+			continue
+		}
+		if s.client.RemoveDir(dir) == nil {
+			removed[dir] = true
+		}
+	}
+	return nil
+}
+
+// replace writes the content of r to a temporary file next to name and
+// renames it to name.
+func (s *SFTP) replace(name string, r io.Reader) error {
+	tmp := path.Join(path.Dir(name), ".musings-upload-"+path.Base(name))
+	if err := s.client.WriteFile(tmp, r, s.fileMode); err != nil {
+		return err
+	}
+	if err := s.client.Rename(tmp, name); err != nil {
+		s.client.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// mkdirAll creates the remote directory dir and its missing parents.
+func (s *SFTP) mkdirAll(dir string) error {
+	if s.dirs[dir] || dir == "." || dir == "/" {
+		return nil
+	}
+	exists, err := s.client.Exists(dir)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := s.mkdirAll(path.Dir(dir)); err != nil {
+			return err
+		}
+		if err := s.client.Mkdir(dir, s.dirMode); err != nil {
+			return fmt.Errorf("error creating %s: %w", dir, err)
+		}
+		if err := s.client.Chmod(dir, s.dirMode); err != nil {
+			return err
+		}
+	}
+	s.dirs[dir] = true
+	return nil
+}
+
+// remote returns the remote path of p, a path relative to the site root.
+func (s *SFTP) remote(p string) string {
+	return path.Join(s.Config.Dir, p)
+}
@@ -0,0 +1,478 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/m4xw311/musing/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// startSFTPServer starts an SSH server on the loopback interface that serves
+// the local file system over SFTP, and returns the configuration of an SFTP
+// target deploying to a temporary directory on it. Like servers in the
+// wild, it applies a umask to created files and refuses plain renames over
+// existing files; offerPosixRename controls whether it offers the extension.
+func startSFTPServer(t *testing.T, offerPosixRename bool) config.SFTPConfig {
+	t.Helper()
+	dir := t.TempDir()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPublic, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == "deploy" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	serverConfig.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, serverConfig, offerPosixRename)
+		}
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := fmt.Sprintf("[127.0.0.1]:%d %s", port, ssh.MarshalAuthorizedKey(hostSigner.PublicKey()))
+	if err := os.WriteFile(knownHosts, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the key file may be used, not the keys of a running agent
+	t.Setenv("SSH_AUTH_SOCK", "")
+	root := filepath.Join(dir, "www")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return config.SFTPConfig{
+		Host:       "127.0.0.1",
+		Port:       port,
+		User:       "deploy",
+		KeyFile:    keyFile,
+		KnownHosts: knownHosts,
+		Dir:        root,
+		FileMode:   "0644",
+		DirMode:    "0755",
+	}
+}
+
+// serveSSH accepts the sessions of an SSH connection and serves SFTP on them.
+func serveSSH(conn net.Conn, serverConfig *ssh.ServerConfig, offerPosixRename bool) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for nc := range channels {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				payload, _, _ := readString(req.Payload)
+				ok := req.Type == "subsystem" && payload == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					go func() {
+						serveSFTP(ch, offerPosixRename)
+						ch.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// serveSFTP answers SFTP requests on rw until the stream ends.
+func serveSFTP(rw io.ReadWriter, offerPosixRename bool) {
+	c := &sftpClient{r: rw, w: rw}
+	files := make(map[string]*os.File)
+	handles := 0
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	reply := func(typ byte, id uint32, args ...string) {
+		var p sftpPacket
+		p.byte(typ)
+		p.uint32(id)
+		for _, arg := range args {
+			p.string(arg)
+		}
+		c.send(p)
+	}
+	status := func(id uint32, err error) {
+		code := uint32(sftpOK)
+		switch {
+		case err == io.EOF:
+			code = sftpEOF
+		case errors.Is(err, os.ErrNotExist):
+			code = sftpNoSuchFile
+		case err != nil:
+			code = 4 // SSH_FX_FAILURE
+		}
+		var p sftpPacket
+		p.byte(sftpStatus)
+		p.uint32(id)
+		p.uint32(code)
+		if err != nil {
+			p.string(err.Error())
+		} else {
+			p.string("")
+		}
+		p.string("")
+		c.send(p)
+	}
+
+	for {
+		typ, data, err := c.receive()
+		if err != nil {
+			return
+		}
+		if typ == sftpInit {
+			var p sftpPacket
+			p.byte(sftpVersion)
+			p.uint32(3)
+			if offerPosixRename {
+				p.string(posixRename)
+				p.string("1")
+			}
+			c.send(p)
+			continue
+		}
+
+		r := &packetReader{data: data}
+		id := r.uint32()
+		switch typ {
+		case sftpOpen:
+			name, flags, perm := r.string(), r.uint32(), r.perm(0644)
+			mode := os.O_RDONLY
+			if flags&sftpFlagWrite != 0 {
+				mode = os.O_WRONLY
+			}
+			if flags&sftpFlagCreat != 0 {
+				mode |= os.O_CREATE
+			}
+			if flags&sftpFlagTrunc != 0 {
+				mode |= os.O_TRUNC
+			}
+			f, err := os.OpenFile(name, mode, perm&^0077)
+			if err != nil {
+				status(id, err)
+				continue
+			}
+			handles++
+			handle := strconv.Itoa(handles)
+			files[handle] = f
+			reply(sftpHandle, id, handle)
+		case sftpClose:
+			handle := r.string()
+			status(id, files[handle].Close())
+			delete(files, handle)
+		case sftpRead:
+			f, offset, length := files[r.string()], r.uint64(), r.uint32()
+			buf := make([]byte, length)
+			n, err := f.ReadAt(buf, int64(offset))
+			if n == 0 {
+				status(id, err)
+				continue
+			}
+			reply(sftpData, id, string(buf[:n]))
+		case sftpWrite:
+			f, offset, content := files[r.string()], r.uint64(), r.string()
+			_, err := f.WriteAt([]byte(content), int64(offset))
+			status(id, err)
+		case sftpSetstat:
+			name := r.string()
+			status(id, os.Chmod(name, r.perm(0)))
+		case sftpFsetstat:
+			f := files[r.string()]
+			status(id, f.Chmod(r.perm(0)))
+		case sftpRemove:
+			name := r.string()
+			if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+				status(id, fmt.Errorf("%s is a directory", name))
+				continue
+			}
+			status(id, os.Remove(name))
+		case sftpMkdir:
+			name := r.string()
+			status(id, os.Mkdir(name, r.perm(0755)&^0077))
+		case sftpRmdir:
+			status(id, removeEmptyDir(r.string()))
+		case sftpStat:
+			if _, err := os.Stat(r.string()); err != nil {
+				status(id, err)
+				continue
+			}
+			var p sftpPacket
+			p.byte(sftpAttrs)
+			p.uint32(id)
+			p.uint32(0)
+			c.send(p)
+		case sftpRename:
+			from, to := r.string(), r.string()
+			if _, err := os.Stat(to); err == nil {
+				status(id, fmt.Errorf("%s already exists", to))
+				continue
+			}
+			status(id, os.Rename(from, to))
+		case sftpExtended:
+			if !offerPosixRename || r.string() != posixRename {
+				status(id, errors.New("unsupported extension"))
+				continue
+			}
+			from, to := r.string(), r.string()
+			status(id, os.Rename(from, to))
+		default:
+			status(id, fmt.Errorf("unsupported request %d", typ))
+		}
+	}
+}
+
+// removeEmptyDir removes the directory at name, failing like rmdir(2) if
+// it is not empty.
+func removeEmptyDir(name string) error {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", name)
+	}
+	return os.Remove(name)
+}
+
+// packetReader decodes the fields of a request in order.
+type packetReader struct {
+	data []byte
+}
+
+func (r *packetReader) uint32() uint32 {
+	v, data, _ := readUint32(r.data)
+	r.data = data
+	return v
+}
+
+func (r *packetReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *packetReader) string() string {
+	s, data, _ := readString(r.data)
+	r.data = data
+	return s
+}
+
+// perm decodes attributes and returns their permissions, or def if they
+// have none.
+func (r *packetReader) perm(def os.FileMode) os.FileMode {
+	if r.uint32()&sftpAttrPermissions == 0 {
+		return def
+	}
+	return os.FileMode(r.uint32())
+}
+
+func dialSFTP(t *testing.T, offerPosixRename bool) *SFTP {
+	t.Helper()
+	s, err := NewSFTP(startSFTPServer(t, offerPosixRename))
+	if err != nil {
+		t.Fatalf("NewSFTP: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSFTPWriteReadFile(t *testing.T) {
+	s := dialSFTP(t, true)
+	name := filepath.Join(s.Config.Dir, "big.bin")
+
+	// Several chunks with a partial one at the end
+	content := bytes.Repeat([]byte("0123456789abcdef"), 3*sftpChunk/16+5)
+	if err := s.client.WriteFile(name, bytes.NewReader(content), 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("written file has mode %v, want 0640 despite the server umask", fi.Mode().Perm())
+	}
+
+	got, err := s.client.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("ReadFile returned %d bytes, want %d", len(got), len(content))
+	}
+
+	// Rewriting truncates, and an empty file reads as empty up to EOF
+	if err := s.client.WriteFile(name, strings.NewReader(""), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if got, err := s.client.ReadFile(name); err != nil || len(got) != 0 {
+		t.Errorf("ReadFile of an empty file = %q, %v", got, err)
+	}
+
+	_, err = s.client.ReadFile(filepath.Join(s.Config.Dir, "missing"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadFile of a missing file: %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestSFTPRename(t *testing.T) {
+	for _, posix := range []bool{true, false} {
+		t.Run(fmt.Sprintf("posix-rename=%v", posix), func(t *testing.T) {
+			s := dialSFTP(t, posix)
+			if _, ok := s.client.extensions[posixRename]; ok != posix {
+				t.Fatalf("extension offered = %v, want %v", ok, posix)
+			}
+			from := filepath.Join(s.Config.Dir, "new")
+			to := filepath.Join(s.Config.Dir, "page.html")
+
+			for _, content := range []string{"first", "second"} {
+				if err := os.WriteFile(from, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.client.Rename(from, to); err != nil {
+					t.Fatalf("Rename: %v", err)
+				}
+				got, err := os.ReadFile(to)
+				if err != nil || string(got) != content {
+					t.Errorf("renamed file = %q, %v, want %q", got, err, content)
+				}
+				if _, err := os.Stat(from); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("source still exists after rename: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSFTPMkdirRemoveDir(t *testing.T) {
+	s := dialSFTP(t, true)
+	dir := filepath.Join(s.Config.Dir, "posts")
+
+	if exists, err := s.client.Exists(dir); err != nil || exists {
+		t.Fatalf("Exists before Mkdir = %v, %v", exists, err)
+	}
+	if err := s.client.Mkdir(dir, 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if exists, err := s.client.Exists(dir); err != nil || !exists {
+		t.Fatalf("Exists after Mkdir = %v, %v", exists, err)
+	}
+	if err := s.client.Mkdir(dir, 0755); err == nil {
+		t.Error("Mkdir of an existing directory succeeded")
+	}
+
+	file := filepath.Join(dir, "a.html")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.client.RemoveDir(dir); err == nil {
+		t.Error("RemoveDir of a non-empty directory succeeded")
+	}
+	if err := s.client.Remove(file); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := s.client.RemoveDir(dir); err != nil {
+		t.Fatalf("RemoveDir: %v", err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory still exists: %v", err)
+	}
+}
+
+func TestSFTPDeploy(t *testing.T) {
+	s := dialSFTP(t, false)
+	first := publishSite(t, map[string]string{"index.html": "one", "posts/a/index.html": "a"})
+	if _, err := Deploy(s, first, Options{Delete: true}); err != nil {
+		t.Fatalf("first deploy: %v", err)
+	}
+	second := publishSite(t, map[string]string{"index.html": "two"})
+	if _, err := Deploy(s, second, Options{Delete: true}); err != nil {
+		t.Fatalf("second deploy: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(s.Config.Dir, "index.html"))
+	if err != nil || string(got) != "two" {
+		t.Errorf("index.html = %q, %v", got, err)
+	}
+	fi, err := os.Stat(filepath.Join(s.Config.Dir, "index.html"))
+	if err == nil && fi.Mode().Perm() != 0644 {
+		t.Errorf("index.html has mode %v, want 0644", fi.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(s.Config.Dir, "posts")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("emptied directories were not removed: %v", err)
+	}
+	stored, err := s.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Files) != 1 || stored.Files[0].Path != "index.html" {
+		t.Errorf("deployed manifest lists %v", paths(stored.Files))
+	}
+}
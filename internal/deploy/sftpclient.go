@@ -0,0 +1,455 @@
+package deploy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// The subset of SFTP version 3 (draft-ietf-secsh-filexfer-02) the SFTP
+// target needs. Requests are sent one at a time and wait for their
+// response, which keeps the client small; deploys transfer few large files.
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpAttrs    = 105
+	sftpExtended = 200
+
+	sftpFlagRead  = 0x01
+	sftpFlagWrite = 0x02
+	sftpFlagCreat = 0x08
+	sftpFlagTrunc = 0x10
+
+	sftpAttrPermissions = 0x04
+
+	sftpOK         = 0
+	sftpEOF        = 1
+	sftpNoSuchFile = 2
+
+	// sftpChunk is the largest amount of data read or written per request;
+	// servers must accept at least 32768 bytes.
+	sftpChunk = 32768
+
+	// posixRename is the OpenSSH extension that replaces an existing file,
+	// which plain SFTP renames refuse to do.
+	posixRename = "posix-rename@openssh.com"
+)
+
+// sftpClient speaks SFTP over a stream, usually the "sftp" subsystem of an
+// SSH session.
+type sftpClient struct {
+	r          io.Reader
+	w          io.Writer
+	id         uint32
+	extensions map[string]string
+}
+
+// sftpError is a failure reported by the server in a status response.
+type sftpError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *sftpError) Error() string {
+	return fmt.Sprintf("sftp: %s (code %d)", e.Message, e.Code)
+}
+
+// Is makes a missing file match os.ErrNotExist.
+func (e *sftpError) Is(target error) bool {
+	return target == os.ErrNotExist && e.Code == sftpNoSuchFile
+}
+
+// newSFTPClient starts an SFTP session on the stream.
+func newSFTPClient(r io.Reader, w io.Writer) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w, extensions: make(map[string]string)}
+
+	var p sftpPacket
+	p.byte(sftpInit)
+	p.uint32(3)
+	if err := c.send(p); err != nil {
+		return nil, err
+	}
+	typ, data, err := c.receive()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet type %d during handshake", typ)
+	}
+	if _, data, err = readUint32(data); err != nil {
+		return nil, err
+	}
+	for len(data) > 0 {
+		var name, value string
+		if name, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		if value, data, err = readString(data); err != nil {
+			return nil, err
+		}
+		c.extensions[name] = value
+	}
+	return c, nil
+}
+
+// ReadFile returns the content of the file at path.
+func (c *sftpClient) ReadFile(path string) ([]byte, error) {
+	handle, err := c.open(path, sftpFlagRead, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	for {
+		var p sftpPacket
+		p.byte(sftpRead)
+		p.uint32(c.next())
+		p.string(handle)
+		p.uint64(uint64(len(content)))
+		p.uint32(sftpChunk)
+		typ, data, err := c.request(p)
+		if err != nil {
+			c.close(handle)
+			return nil, err
+		}
+		if typ == sftpStatus {
+			err := statusError(data)
+			if e, ok := err.(*sftpError); ok && e.Code == sftpEOF {
+				break
+			}
+			if err == nil {
+				err = fmt.Errorf("sftp: no data returned reading %s", path)
+			}
+			c.close(handle)
+			return nil, err
+		}
+		if typ != sftpData {
+			c.close(handle)
+			return nil, fmt.Errorf("sftp: unexpected packet type %d reading %s", typ, path)
+		}
+		chunk, _, err := readString(data)
+		if err != nil {
+			c.close(handle)
+			return nil, err
+		}
+		content = append(content, chunk...)
+	}
+	return content, c.close(handle)
+}
+
+// WriteFile creates or truncates the file at path, writes the content of r
+// to it and sets its permissions to mode.
+func (c *sftpClient) WriteFile(path string, r io.Reader, mode os.FileMode) error {
+	perm := uint32(mode.Perm())
+	handle, err := c.open(path, sftpFlagWrite|sftpFlagCreat|sftpFlagTrunc, &perm)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, sftpChunk)
+	var offset uint64
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			var p sftpPacket
+			p.byte(sftpWrite)
+			p.uint32(c.next())
+			p.string(handle)
+			p.uint64(offset)
+			p.string(string(buf[:n]))
+			if err := c.expectOK(p); err != nil {
+				c.close(handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			c.close(handle)
+			return err
+		}
+	}
+
+	// Set the permissions explicitly, as servers apply their umask to those
+	// given when a file is created
+	var p sftpPacket
+	p.byte(sftpFsetstat)
+	p.uint32(c.next())
+	p.string(handle)
+	p.uint32(sftpAttrPermissions)
+	p.uint32(perm)
+	if err := c.expectOK(p); err != nil {
+		c.close(handle)
+		return err
+	}
+	return c.close(handle)
+}
+
+// Rename moves the file at from to to, replacing to if it exists.
+func (c *sftpClient) Rename(from, to string) error {
+	var p sftpPacket
+	if _, ok := c.extensions[posixRename]; ok {
+		p.byte(sftpExtended)
+		p.uint32(c.next())
+		p.string(posixRename)
+	} else {
+		// Without the extension the target has to go first, which briefly
+		// leaves no file at to
+		if err := c.Remove(to); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		p.byte(sftpRename)
+		p.uint32(c.next())
+	}
+	p.string(from)
+	p.string(to)
+	return c.expectOK(p)
+}
+
+// Remove deletes the file at path.
+func (c *sftpClient) Remove(path string) error {
+	return c.pathRequest(sftpRemove, path)
+}
+
+// RemoveDir deletes the empty directory at path.
+func (c *sftpClient) RemoveDir(path string) error {
+	return c.pathRequest(sftpRmdir, path)
+}
+
+// Mkdir creates the directory at path with the permissions mode.
+func (c *sftpClient) Mkdir(path string, mode os.FileMode) error {
+	var p sftpPacket
+	p.byte(sftpMkdir)
+	p.uint32(c.next())
+	p.string(path)
+	p.uint32(sftpAttrPermissions)
+	p.uint32(uint32(mode.Perm()))
+	return c.expectOK(p)
+}
+
+// Chmod sets the permissions of the file or directory at path to mode.
+func (c *sftpClient) Chmod(path string, mode os.FileMode) error {
+	var p sftpPacket
+	p.byte(sftpSetstat)
+	p.uint32(c.next())
+	p.string(path)
+	p.uint32(sftpAttrPermissions)
+	p.uint32(uint32(mode.Perm()))
+	return c.expectOK(p)
+}
+
+// Exists reports whether there is a file or directory at path.
+func (c *sftpClient) Exists(path string) (bool, error) {
+	var p sftpPacket
+	p.byte(sftpStat)
+	p.uint32(c.next())
+	p.string(path)
+	typ, data, err := c.request(p)
+	if err != nil {
+		return false, err
+	}
+	switch typ {
+	case sftpAttrs:
+		return true, nil
+	case sftpStatus:
+		err := statusError(data)
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("sftp: no attributes returned for %s", path)
+		}
+		return false, err
+	}
+	return false, fmt.Errorf("sftp: unexpected packet type %d for stat", typ)
+}
+
+// open opens the file at path and returns its handle. If perm is not nil,
+// it is passed as the permissions of a created file.
+func (c *sftpClient) open(path string, flags uint32, perm *uint32) (string, error) {
+	var p sftpPacket
+	p.byte(sftpOpen)
+	p.uint32(c.next())
+	p.string(path)
+	p.uint32(flags)
+	if perm != nil {
+		p.uint32(sftpAttrPermissions)
+		p.uint32(*perm)
+	} else {
+		p.uint32(0)
+	}
+
+	typ, data, err := c.request(p)
+	if err != nil {
+		return "", err
+	}
+	switch typ {
+	case sftpHandle:
+		handle, _, err := readString(data)
+		return handle, err
+	case sftpStatus:
+		if err := statusError(data); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("sftp: unexpected packet type %d opening %s", typ, path)
+}
+
+// close releases a handle returned by open.
+func (c *sftpClient) close(handle string) error {
+	var p sftpPacket
+	p.byte(sftpClose)
+	p.uint32(c.next())
+	p.string(handle)
+	return c.expectOK(p)
+}
+
+// pathRequest sends a request whose only argument is a path and waits for
+// its status.
+func (c *sftpClient) pathRequest(typ byte, path string) error {
+	var p sftpPacket
+	p.byte(typ)
+	p.uint32(c.next())
+	p.string(path)
+	return c.expectOK(p)
+}
+
+// expectOK sends a request and returns the error in its status response.
+func (c *sftpClient) expectOK(p sftpPacket) error {
+	typ, data, err := c.request(p)
+	if err != nil {
+		return err
+	}
+	if typ != sftpStatus {
+		return fmt.Errorf("sftp: unexpected packet type %d, expected status", typ)
+	}
+	return statusError(data)
+}
+
+// request sends a request and returns the type and payload of its response,
+// after the request ID.
+func (c *sftpClient) request(p sftpPacket) (byte, []byte, error) {
+	if err := c.send(p); err != nil {
+		return 0, nil, err
+	}
+	typ, data, err := c.receive()
+	if err != nil {
+		return 0, nil, err
+	}
+	id, data, err := readUint32(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	if id != c.id {
+		return 0, nil, fmt.Errorf("sftp: response for request %d, expected %d", id, c.id)
+	}
+	return typ, data, nil
+}
+
+// next returns the ID for a new request.
+func (c *sftpClient) next() uint32 {
+	c.id++
+	return c.id
+}
+
+// send writes a packet with its length prefix.
+func (c *sftpClient) send(p sftpPacket) error {
+	msg := make([]byte, 4, 4+len(p.bytes))
+	binary.BigEndian.PutUint32(msg, uint32(len(p.bytes)))
+	_, err := c.w.Write(append(msg, p.bytes...))
+	return err
+}
+
+// receive reads a packet and returns its type and payload.
+func (c *sftpClient) receive() (byte, []byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		return 0, nil, fmt.Errorf("sftp: %w", err)
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, fmt.Errorf("sftp: %w", err)
+	}
+	return data[0], data[1:], nil
+}
+
+// statusError decodes the payload of a status response into an error, nil
+// for success.
+func statusError(data []byte) error {
+	code, data, err := readUint32(data)
+	if err != nil {
+		return err
+	}
+	if code == sftpOK {
+		return nil
+	}
+	msg, _, _ := readString(data)
+	if msg == "" {
+		msg = "request failed"
+	}
+	return &sftpError{Code: code, Message: msg}
+}
+
+// sftpPacket builds the payload of a packet.
+type sftpPacket struct {
+	bytes []byte
+}
+
+func (p *sftpPacket) byte(b byte) {
+	p.bytes = append(p.bytes, b)
+}
+
+func (p *sftpPacket) uint32(v uint32) {
+	p.bytes = binary.BigEndian.AppendUint32(p.bytes, v)
+}
+
+func (p *sftpPacket) uint64(v uint64) {
+	p.bytes = binary.BigEndian.AppendUint64(p.bytes, v)
+}
+
+func (p *sftpPacket) string(s string) {
+	p.uint32(uint32(len(s)))
+	p.bytes = append(p.bytes, s...)
+}
+
+// readUint32 decodes a uint32 from the start of data.
+func readUint32(data []byte) (uint32, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, errors.New("sftp: short packet")
+	}
+	return binary.BigEndian.Uint32(data), data[4:], nil
+}
+
+// readString decodes a length-prefixed string from the start of data.
+func readString(data []byte) (string, []byte, error) {
+	n, data, err := readUint32(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if uint32(len(data)) < n {
+		return "", nil, errors.New("sftp: short packet")
+	}
+	return string(data[:n]), data[n:], nil
+}
@@ -0,0 +1,204 @@
+// Package infra generates the infrastructure as code that hosts a site.
+//
+// The modules are kept as plain Terraform files in the template directory
+// and copied as they are; only the terraform.tfvars file is generated, from
+// the musing configuration, so the modules can be validated and edited like
+// any other Terraform code.
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+// TemplateDir holds a Terraform module for every supported provider.
+const TemplateDir = "internal/template/infra"
+
+// Options controls Init.
+type Options struct {
+	Provider string // Cloud provider, e.g. aws
+	Dir      string // Directory the module is written to
+	Force    bool   // Overwrite files that already exist
+}
+
+// Providers returns the names of the providers a module exists for.
+func Providers() ([]string, error) {
+	entries, err := os.ReadDir(TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	var providers []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			providers = append(providers, entry.Name())
+		}
+	}
+	return providers, nil
+}
+
+// Init writes the Terraform module for the provider to the directory in
+// opts, together with a terraform.tfvars file holding the values derived
+// from cfg and the files the module reads, such as the CloudFront Function
+// written by publish. It returns the paths of the files written and of the
+// files kept. Existing module files are kept unless opts.Force is set, so a
+// module can be edited after it was generated; terraform.tfvars and the
+// copied files are always refreshed, so running Init again after a publish
+// or a configuration change updates them.
+func Init(cfg *config.Config, opts Options) (written, kept []string, err error) {
+	var vars [][2]string
+	files := make(map[string][]byte)
+	refresh := make(map[string]bool)
+	switch opts.Provider {
+	case "aws":
+		vars, err = awsVariables(cfg)
+		if err == nil && slices.Contains(cfg.Hosting.Formats, "cloudfront") {
+			function := filepath.Join(cfg.Hosting.Dir, cloudFrontFunctionFile)
+			data, readErr := os.ReadFile(function)
+			if os.IsNotExist(readErr) {
+				return nil, nil, fmt.Errorf("%s does not exist, run 'musings publish' first", function)
+			} else if readErr != nil {
+				return nil, nil, readErr
+			}
+			files[cloudFrontFunctionFile] = data
+			refresh[cloudFrontFunctionFile] = true
+		}
+	default:
+		providers, _ := Providers()
+		return nil, nil, fmt.Errorf("unknown provider %q (available: %s)", opts.Provider, strings.Join(providers, ", "))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src := filepath.Join(TemplateDir, opts.Provider)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s module: %w", opts.Provider, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tf" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		files[entry.Name()] = data
+	}
+	files["terraform.tfvars"] = tfvars(vars)
+	refresh["terraform.tfvars"] = true
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range names {
+		dst := filepath.Join(opts.Dir, name)
+		if _, err := os.Stat(dst); err == nil && !opts.Force && !refresh[name] {
+			kept = append(kept, dst)
+			continue
+		}
+		if err := os.WriteFile(dst, files[name], 0644); err != nil {
+			return written, kept, err
+		}
+		written = append(written, dst)
+	}
+	return written, kept, nil
+}
+
+// cloudFrontFunctionFile is the name of the CloudFront Function publish
+// writes to the hosting directory, copied into the module under the same
+// name.
+const cloudFrontFunctionFile = "cloudfront-function.js"
+
+// awsVariables returns the values of the variables of the aws module.
+func awsVariables(cfg *config.Config) ([][2]string, error) {
+	u, err := url.Parse(cfg.Site.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	domain := u.Hostname()
+	if domain == "localhost" || net.ParseIP(domain) != nil || !strings.Contains(domain, ".") {
+		return nil, fmt.Errorf("site.base_url must be the public URL of the site, got %q", cfg.Site.BaseURL)
+	}
+	if cfg.Deploy.S3.Bucket == "" {
+		return nil, errors.New("deploy.s3.bucket is not set")
+	}
+
+	// The hosted zone of a subdomain cannot be told from the domain alone,
+	// as in blog.example.com and example.co.uk
+	zone := cfg.Infra.Zone
+	if zone == "" {
+		if strings.Count(domain, ".") > 1 {
+			return nil, fmt.Errorf("infra.zone must name the Route53 hosted zone of %s", domain)
+		}
+		zone = domain
+	}
+
+	region := cfg.Deploy.S3.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// The bucket holds the site below deploy.s3.prefix, which CloudFront
+	// has to add to request paths
+	var originPath string
+	if prefix := strings.Trim(cfg.Deploy.S3.Prefix, "/"); prefix != "" {
+		originPath = "/" + prefix
+	}
+
+	// The CloudFront Function written by publish is copied into the module
+	var function string
+	if slices.Contains(cfg.Hosting.Formats, "cloudfront") {
+		function = cloudFrontFunctionFile
+	}
+
+	return [][2]string{
+		{"domain_name", domain},
+		{"zone_name", zone},
+		{"bucket_name", cfg.Deploy.S3.Bucket},
+		{"region", region},
+		{"origin_path", originPath},
+		{"price_class", cfg.Infra.PriceClass},
+		{"cloudfront_function_file", function},
+	}, nil
+}
+
+// tfvars returns a terraform.tfvars file setting the string variables in
+// vars, aligned like terraform fmt does.
+func tfvars(vars [][2]string) []byte {
+	width := 0
+	for _, v := range vars {
+		width = max(width, len(v[0]))
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by musings infra init from musing.yaml\n\n")
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%-*s = %s\n", width, v[0], hclString(v[1]))
+	}
+	return []byte(b.String())
+}
+
+// hclString returns s as a quoted HCL string. Template sequences are
+// escaped so the value is taken literally.
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
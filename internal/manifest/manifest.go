@@ -0,0 +1,189 @@
+// Package manifest describes the files of a published site.
+//
+// A manifest lists every file in the output directory with its size, SHA-256
+// checksum and content type. It is written next to the site on every publish
+// and deployed with it, so two builds, or a build and what is deployed, can
+// be compared and deployments limited to the files that changed.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the manifest in the output directory.
+const FileName = "musing-manifest.json"
+
+// Entry describes one file of the site.
+type Entry struct {
+	Path        string `json:"path"` // Slash-separated path relative to the site root
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"` // Hex-encoded checksum of the content
+	ContentType string `json:"content_type"`
+	Generated   bool   `json:"generated,omitempty"` // Written by publish rather than placed in the output directory by hand
+}
+
+// Manifest lists the files of a site, sorted by path.
+type Manifest struct {
+	Files []Entry `json:"files"`
+}
+
+// Build creates the manifest of every file below dir, except the manifest
+// itself.
+func Build(dir string) (*Manifest, error) {
+	m := &Manifest{Files: []Entry{}}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == FileName {
+			return nil
+		}
+
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, Entry{
+			Path:        rel,
+			Size:        info.Size(),
+			SHA256:      sum,
+			ContentType: ContentType(rel),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return m, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Client fetches manifests from URLs, giving up on unresponsive servers.
+var Client = &http.Client{Timeout: 30 * time.Second}
+
+// Load reads a manifest from a file path or an http(s) URL. A manifest that
+// does not exist yields an error satisfying errors.Is(err, os.ErrNotExist).
+func Load(location string) (*Manifest, error) {
+	var data []byte
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		resp, err := Client.Get(location)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound, http.StatusForbidden:
+			return nil, fmt.Errorf("%s: %w", location, os.ErrNotExist)
+		default:
+			return nil, fmt.Errorf("error fetching %s: %s", location, resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(location); err != nil {
+			return nil, err
+		}
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes a manifest from its JSON encoding.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %w", err)
+	}
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	return &m, nil
+}
+
+// Marshal returns the JSON encoding of the manifest.
+func (m *Manifest) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Lookup returns the entry for path, if the manifest has one.
+func (m *Manifest) Lookup(path string) (Entry, bool) {
+	i := sort.Search(len(m.Files), func(i int) bool { return m.Files[i].Path >= path })
+	if i < len(m.Files) && m.Files[i].Path == path {
+		return m.Files[i], true
+	}
+	return Entry{}, false
+}
+
+// Diff lists the differences between two manifests.
+type Diff struct {
+	Added   []Entry // Files only in the new manifest
+	Changed []Entry // Files whose content differs, as in the new manifest
+	Removed []Entry // Files only in the old manifest
+}
+
+// Empty reports whether the manifests describe the same files.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Changed) == 0 && len(d.Removed) == 0
+}
+
+// Compare returns the differences from the manifest from to the manifest to.
+// Files are compared by checksum and content type.
+func Compare(from, to *Manifest) Diff {
+	var d Diff
+	for _, e := range to.Files {
+		prev, ok := from.Lookup(e.Path)
+		switch {
+		case !ok:
+			d.Added = append(d.Added, e)
+		case prev.SHA256 != e.SHA256 || prev.ContentType != e.ContentType:
+			d.Changed = append(d.Changed, e)
+		}
+	}
+	for _, e := range from.Files {
+		if _, ok := to.Lookup(e.Path); !ok {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	return d
+}
@@ -0,0 +1,383 @@
+package site
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// VendorDir is the theme directory holding third-party front-end assets and
+// the manifest describing where they came from.
+const VendorDir = "internal/template/vendor"
+
+// vendorManifest is the name of the manifest file inside VendorDir.
+const vendorManifest = "assets.json"
+
+// VendorAsset is a third-party file used by the theme, pinned to a versioned
+// upstream URL and its Subresource Integrity hash.
+type VendorAsset struct {
+	Path      string `json:"path"`      // Path below VendorDir and below vendor/ in the output
+	URL       string `json:"url"`       // Upstream URL of the pinned version
+	Integrity string `json:"integrity"` // SRI hash of the file, recorded by "musings vendor"
+}
+
+// AssetRef is a stylesheet or script reference rendered into a page.
+type AssetRef struct {
+	URL       string
+	Integrity string
+}
+
+// PageAssets lists the front-end assets a page needs.
+type PageAssets struct {
+	Styles  []AssetRef
+	Scripts []AssetRef
+	Math    *AssetRef // MathJax, loaded asynchronously
+}
+
+// Paths of the assets pages refer to directly, relative to VendorDir.
+const (
+	prismStyle  = "prism/prism.min.css"
+	prismCore   = "prism/prism-core.min.js"
+	mathJaxPath = "mathjax/tex-mml-chtml.js"
+)
+
+// prismAliases maps code block language names to Prism component names.
+var prismAliases = map[string]string{
+	"html":       "markup",
+	"xml":        "markup",
+	"svg":        "markup",
+	"mathml":     "markup",
+	"js":         "javascript",
+	"ts":         "typescript",
+	"sh":         "bash",
+	"shell":      "bash",
+	"py":         "python",
+	"rb":         "ruby",
+	"yml":        "yaml",
+	"md":         "markdown",
+	"cs":         "csharp",
+	"kt":         "kotlin",
+	"golang":     "go",
+	"c++":        "cpp",
+	"dockerfile": "docker",
+	"tf":         "hcl",
+}
+
+// prismRequires lists the components a Prism component depends on. They must
+// be loaded before it.
+var prismRequires = map[string][]string{
+	"javascript": {"clike"},
+	"c":          {"clike"},
+	"cpp":        {"c"},
+	"csharp":     {"clike"},
+	"go":         {"clike"},
+	"java":       {"clike"},
+	"kotlin":     {"clike"},
+	"ruby":       {"clike"},
+	"markdown":   {"markup"},
+	"typescript": {"javascript"},
+	"jsx":        {"markup", "javascript"},
+	"tsx":        {"jsx", "typescript"},
+}
+
+// codeLanguagePattern matches the language class the markdown renderer puts
+// on fenced code blocks.
+var codeLanguagePattern = regexp.MustCompile(`<code class="language-([^"\s]+)"`)
+
+// LoadVendorAssets reads the vendored asset manifest.
+func LoadVendorAssets() ([]VendorAsset, error) {
+	data, err := os.ReadFile(filepath.Join(VendorDir, vendorManifest))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		Assets []VendorAsset `json:"assets"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", vendorManifest, err)
+	}
+	return manifest.Assets, nil
+}
+
+// SaveVendorAssets writes the vendored asset manifest.
+func SaveVendorAssets(assets []VendorAsset) error {
+	manifest := struct {
+		Assets []VendorAsset `json:"assets"`
+	}{assets}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(VendorDir, vendorManifest), append(data, '\n'), 0644)
+}
+
+// FetchVendorAsset downloads asset into VendorDir, or checks the copy already
+// there unless force is set. The file must match the recorded integrity hash.
+// An asset without one is refused unless pin is set, in which case the hash
+// of the file is recorded in the asset; the file should be checked first, as
+// pinning trusts whatever was downloaded. It reports whether the file was
+// downloaded.
+func FetchVendorAsset(asset *VendorAsset, force, pin bool) (bool, error) {
+	if asset.Integrity == "" && !pin {
+		return false, fmt.Errorf("%s has no recorded integrity hash (add it to %s or pin the downloaded file with --pin)", asset.Path, vendorManifest)
+	}
+	dst := filepath.Join(VendorDir, filepath.FromSlash(asset.Path))
+
+	data, err := os.ReadFile(dst)
+	fetched := false
+	if force || os.IsNotExist(err) {
+		if data, err = download(asset.URL); err != nil {
+			return false, err
+		}
+		fetched = true
+	} else if err != nil {
+		return false, err
+	}
+
+	integrity := subresourceIntegrity(data)
+	if asset.Integrity != "" && asset.Integrity != integrity {
+		return false, fmt.Errorf("%s does not match its integrity hash %s", asset.Path, asset.Integrity)
+	}
+	asset.Integrity = integrity
+
+	if fetched {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return false, err
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return false, err
+		}
+	}
+	return fetched, nil
+}
+
+// downloadClient fetches vendored assets, giving up on unresponsive servers.
+var downloadClient = &http.Client{Timeout: time.Minute}
+
+// download fetches url and returns the response body.
+func download(url string) ([]byte, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error downloading %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// subresourceIntegrity returns the SRI hash of data.
+func subresourceIntegrity(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// vendorFile is a vendored asset to be written to the output directory.
+type vendorFile struct {
+	name string // Path in the output directory
+	data []byte
+}
+
+// loadVendorFiles reads the vendored assets and resolves the reference pages
+// use for each of them. Every vendored file must match its recorded integrity
+// hash, and every post must only need assets that have been vendored, which
+// is checked before the build writes anything.
+func (s *StaticSiteGenerator) loadVendorFiles(b *blog.Blog) ([]vendorFile, error) {
+	assets, err := LoadVendorAssets()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []vendorFile
+	s.assets = make(map[string]AssetRef, len(assets))
+	s.missing = make(map[string]bool)
+	for _, asset := range assets {
+		src := filepath.Join(VendorDir, filepath.FromSlash(asset.Path))
+		data, err := os.ReadFile(src)
+		if os.IsNotExist(err) {
+			s.missing[asset.Path] = true
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		integrity := subresourceIntegrity(data)
+		if asset.Integrity == "" {
+			return nil, fmt.Errorf("vendored asset %s has no integrity hash (check the file and run 'musings vendor --pin')", asset.Path)
+		}
+		if asset.Integrity != integrity {
+			return nil, fmt.Errorf("vendored asset %s does not match its integrity hash %s", asset.Path, asset.Integrity)
+		}
+
+		// Fonts and other files are loaded by name from the scripts, so
+		// only style sheets and scripts can be fingerprinted
+		name := path.Join("vendor", asset.Path)
+		if ext := path.Ext(name); s.Config.Assets.Fingerprint && (ext == ".css" || ext == ".js") {
+			name = fingerprint(name, data)
+		}
+
+		files = append(files, vendorFile{name: name, data: data})
+		s.assets[asset.Path] = AssetRef{URL: name, Integrity: integrity}
+	}
+
+	for _, post := range b.Posts {
+		if _, err := s.pageAssets(post.ContentHTML); err != nil {
+			return nil, fmt.Errorf("%s: %w", post.SourcePath, err)
+		}
+	}
+	return files, nil
+}
+
+// copyVendorAssets writes the vendored assets to vendor/ in the output
+// directory.
+func (s *StaticSiteGenerator) copyVendorAssets(files []vendorFile) error {
+	for _, f := range files {
+		if err := s.writeOutput(f.name, f.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeThemeAsset writes the named style sheet or script from the template
+// directory to the output directory, minified and under a fingerprinted name
+// if enabled. Pages refer to it through the asset template function.
+func (s *StaticSiteGenerator) writeThemeAsset(name string) error {
+	data, err := os.ReadFile(filepath.Join("internal/template", name))
+	if err != nil {
+		return err
+	}
+
+	if s.Config.Assets.Minify {
+		switch path.Ext(name) {
+		case ".css":
+			data = []byte(minifyCSS(string(data)))
+		case ".js":
+			data = []byte(minifyJS(string(data)))
+		}
+	}
+
+	out := name
+	if s.Config.Assets.Fingerprint {
+		out = fingerprint(name, data)
+	}
+	if s.hashed == nil {
+		s.hashed = make(map[string]string)
+	}
+	s.hashed[name] = out
+
+	return s.writeOutput(out, data)
+}
+
+// assetName returns the output name of the named theme asset.
+func (s *StaticSiteGenerator) assetName(name string) string {
+	if out, ok := s.hashed[name]; ok {
+		return out
+	}
+	return name
+}
+
+// fingerprint inserts a hash of data into name before its extension, so the
+// name changes whenever the content does and the file can be cached forever.
+func fingerprint(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+}
+
+// pageAssets returns the assets needed to display the given rendered
+// content: MathJax when it contains math, and Prism with the components for
+// the languages of its code blocks when it contains highlighted code. It
+// fails if a needed asset has not been vendored.
+func (s *StaticSiteGenerator) pageAssets(contents ...template.HTML) (PageAssets, error) {
+	var pa PageAssets
+	var languages []string
+	seen := make(map[string]bool)
+
+	for _, content := range contents {
+		html := string(content)
+		if pa.Math == nil && strings.Contains(html, `class="math`) {
+			ref, err := s.vendorRef(mathJaxPath)
+			if err != nil {
+				return pa, err
+			}
+			pa.Math = &ref
+		}
+		for _, m := range codeLanguagePattern.FindAllStringSubmatch(html, -1) {
+			languages = s.addPrismComponent(languages, seen, strings.ToLower(m[1]))
+		}
+	}
+
+	if len(languages) == 0 {
+		return pa, nil
+	}
+	style, err := s.vendorRef(prismStyle)
+	if err != nil {
+		return pa, err
+	}
+	pa.Styles = append(pa.Styles, style)
+	for _, name := range append([]string{prismCore}, languages...) {
+		if name != prismCore {
+			name = prismComponent(name)
+		}
+		ref, err := s.vendorRef(name)
+		if err != nil {
+			return pa, err
+		}
+		pa.Scripts = append(pa.Scripts, ref)
+	}
+	return pa, nil
+}
+
+// vendorRef returns the page reference to the vendored asset at path.
+func (s *StaticSiteGenerator) vendorRef(path string) (AssetRef, error) {
+	ref, ok := s.assets[path]
+	if !ok {
+		return AssetRef{}, fmt.Errorf("vendored asset %s is missing (run 'musings vendor')", path)
+	}
+	return ref, nil
+}
+
+// addPrismComponent appends the Prism component for lang to components after
+// the components it depends on, skipping languages the vendor manifest has
+// no component for and ones already present.
+func (s *StaticSiteGenerator) addPrismComponent(components []string, seen map[string]bool, lang string) []string {
+	if alias, ok := prismAliases[lang]; ok {
+		lang = alias
+	}
+	if seen[lang] {
+		return components
+	}
+	seen[lang] = true
+
+	if _, ok := s.assets[prismComponent(lang)]; !ok && !s.missing[prismComponent(lang)] {
+		return components
+	}
+	for _, dep := range prismRequires[lang] {
+		components = s.addPrismComponent(components, seen, dep)
+	}
+	return append(components, lang)
+}
+
+// prismComponent returns the vendored path of the Prism component for lang.
+func prismComponent(lang string) string {
+	return "prism/components/prism-" + lang + ".min.js"
+}
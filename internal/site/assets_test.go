@@ -0,0 +1,74 @@
+package site
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestPageAssets(t *testing.T) {
+	s := &StaticSiteGenerator{
+		assets: map[string]AssetRef{
+			prismStyle:                   {URL: "vendor/prism/prism.min.css"},
+			prismCore:                    {URL: "vendor/prism/prism-core.min.js"},
+			prismComponent("clike"):      {URL: "clike"},
+			prismComponent("javascript"): {URL: "javascript"},
+			prismComponent("go"):         {URL: "go"},
+			prismComponent("python"):     {URL: "python"},
+		},
+		missing: map[string]bool{
+			mathJaxPath:            true,
+			prismComponent("ruby"): true,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		scripts []string
+		wantErr string
+	}{
+		{"plain text", "<p>x</p>", nil, ""},
+		{"dependencies first", `<code class="language-js">`, []string{"vendor/prism/prism-core.min.js", "clike", "javascript"}, ""},
+		{"shared dependency once", `<code class="language-go"></code><code class="language-javascript">`, []string{"vendor/prism/prism-core.min.js", "clike", "go", "javascript"}, ""},
+		{"aliases", `<code class="language-PY">`, []string{"vendor/prism/prism-core.min.js", "python"}, ""},
+		{"unknown language", `<code class="language-mermaid">`, nil, ""},
+		{"missing component", `<code class="language-ruby">`, nil, "prism/components/prism-ruby.min.js is missing"},
+		{"missing math", `<span class="math inline">`, nil, "mathjax/tex-mml-chtml.js is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pa, err := s.pageAssets(template.HTML(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var scripts []string
+			for _, ref := range pa.Scripts {
+				scripts = append(scripts, ref.URL)
+			}
+			if strings.Join(scripts, " ") != strings.Join(tt.scripts, " ") {
+				t.Errorf("scripts %v, want %v", scripts, tt.scripts)
+			}
+			if (len(pa.Styles) > 0) != (len(tt.scripts) > 0) {
+				t.Errorf("styles %v with scripts %v", pa.Styles, scripts)
+			}
+		})
+	}
+}
+
+func TestFetchVendorAssetRequiresPin(t *testing.T) {
+	asset := VendorAsset{Path: "prism/prism-core.min.js", URL: "https://example.invalid/prism-core.min.js"}
+	_, err := FetchVendorAsset(&asset, false, false)
+	if err == nil || !strings.Contains(err.Error(), "no recorded integrity hash") {
+		t.Errorf("FetchVendorAsset without a hash: %v", err)
+	}
+	if asset.Integrity != "" {
+		t.Errorf("recorded integrity %q without --pin", asset.Integrity)
+	}
+}
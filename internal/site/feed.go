@@ -3,37 +3,100 @@ package site
 import (
 	"encoding/xml"
 	"fmt"
+	"html/template"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/manifest"
 )
 
 // RSSFeed represents an RSS feed
 type RSSFeed struct {
-	XMLName xml.Name    `xml:"rss"`
-	Version string      `xml:"version,attr"`
-	Channel *RSSChannel `xml:"channel"`
+	XMLName      xml.Name    `xml:"rss"`
+	Version      string      `xml:"version,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr,omitempty"`
+	XmlnsContent string      `xml:"xmlns:content,attr,omitempty"`
+	XmlnsAtom    string      `xml:"xmlns:atom,attr,omitempty"`
+	XmlnsITunes  string      `xml:"xmlns:itunes,attr,omitempty"`
+	Channel      *RSSChannel `xml:"channel"`
 }
 
 // RSSChannel represents an RSS channel
 type RSSChannel struct {
-	Title       string    `xml:"title"`
-	Link        string    `xml:"link"`
-	Description string    `xml:"description"`
-	Language    string    `xml:"language,omitempty"`
-	PubDate     string    `xml:"pubDate,omitempty"`
-	Items       []RSSItem `xml:"item"`
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	AtomLink      *AtomLink `xml:"atom:link,omitempty"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	PubDate       string    `xml:"pubDate,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+
+	// iTunes podcast extensions, only set on podcast feeds
+	ITunesAuthor   string          `xml:"itunes:author,omitempty"`
+	ITunesSummary  string          `xml:"itunes:summary,omitempty"`
+	ITunesOwner    *ITunesOwner    `xml:"itunes:owner,omitempty"`
+	ITunesImage    *ITunesImage    `xml:"itunes:image,omitempty"`
+	ITunesCategory *ITunesCategory `xml:"itunes:category,omitempty"`
+	ITunesExplicit string          `xml:"itunes:explicit,omitempty"`
+	ITunesType     string          `xml:"itunes:type,omitempty"`
+
+	Items []RSSItem `xml:"item"`
 }
 
 // RSSItem represents an RSS item
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
+	Title          string        `xml:"title"`
+	Link           string        `xml:"link"`
+	Description    string        `xml:"description"`
+	ContentEncoded *RSSCDATA     `xml:"content:encoded,omitempty"`
+	PubDate        string        `xml:"pubDate"`
+	GUID           RSSGUID       `xml:"guid"`
+	Creators       []string      `xml:"dc:creator"`
+	Categories     []string      `xml:"category"`
+	Enclosure      *RSSEnclosure `xml:"enclosure,omitempty"`
+
+	// iTunes podcast extensions, only set on podcast feeds
+	ITunesDuration string       `xml:"itunes:duration,omitempty"`
+	ITunesEpisode  int          `xml:"itunes:episode,omitempty"`
+	ITunesExplicit string       `xml:"itunes:explicit,omitempty"`
+	ITunesImage    *ITunesImage `xml:"itunes:image,omitempty"`
+}
+
+// RSSGUID represents the unique identifier of an RSS item
+type RSSGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr,omitempty"`
+}
+
+// RSSCDATA represents character data written as a CDATA section
+type RSSCDATA struct {
+	Body string `xml:",cdata"`
+}
+
+// RSSEnclosure represents a media object attached to an RSS item
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// ITunesOwner represents the owner contact of a podcast
+type ITunesOwner struct {
+	Name  string `xml:"itunes:name"`
+	Email string `xml:"itunes:email"`
+}
+
+// ITunesImage represents podcast or episode artwork
+type ITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+// ITunesCategory represents an Apple Podcasts category
+type ITunesCategory struct {
+	Text string `xml:"text,attr"`
 }
 
 // AtomFeed represents an Atom feed
@@ -50,17 +113,20 @@ type AtomFeed struct {
 
 // AtomAuthor represents an Atom author
 type AtomAuthor struct {
-	Name string `xml:"name"`
+	Name  string `xml:"name"`
+	URI   string `xml:"uri,omitempty"`
+	Email string `xml:"email,omitempty"`
 }
 
 // AtomEntry represents an Atom entry
 type AtomEntry struct {
-	Title   string      `xml:"title"`
-	ID      string      `xml:"id"`
-	Link    AtomLink    `xml:"link"`
-	Updated string      `xml:"updated"`
-	Summary string      `xml:"summary"`
-	Content AtomContent `xml:"content"`
+	Title   string       `xml:"title"`
+	ID      string       `xml:"id"`
+	Link    AtomLink     `xml:"link"`
+	Updated string       `xml:"updated"`
+	Authors []AtomAuthor `xml:"author"`
+	Summary string       `xml:"summary"`
+	Content *AtomContent `xml:"content,omitempty"`
 }
 
 // AtomLink represents an Atom link
@@ -78,125 +144,225 @@ type AtomContent struct {
 
 // generateRSSFeed creates an RSS feed from blog posts
 func (s *StaticSiteGenerator) generateRSSFeed(b *blog.Blog) error {
+	return s.writeRSSFeed(b.Posts, "rss.xml", s.Config.Site.Title)
+}
+
+// writeRSSFeed writes an RSS feed of the published posts to name in the
+// output directory.
+func (s *StaticSiteGenerator) writeRSSFeed(posts []blog.Post, name, title string) error {
+	site := s.Config.Site
 	channel := &RSSChannel{
-		Title:       "My Blog",               // This should be configurable
-		Link:        "http://localhost:8080", // This should be configurable
-		Description: "A blog about technology and programming",
-		Language:    "en-us",
+		Title: title,
+		Link:  site.BaseURL,
+		AtomLink: &AtomLink{
+			Href: s.absoluteURL(name),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		},
+		Description: site.Description,
+		Language:    site.Language,
 	}
+	var lastBuild time.Time
 
 	// Add items for each post
-	for _, post := range b.Posts {
-		if !post.Published {
-			continue
-		}
-
+	for _, post := range s.feedPosts(posts) {
 		pubDate := post.CreatedDate.Format(time.RFC1123Z)
 		if channel.PubDate == "" {
 			channel.PubDate = pubDate
 		}
+		if post.UpdatedDate.After(lastBuild) {
+			lastBuild = post.UpdatedDate
+		}
 
 		item := RSSItem{
 			Title:       post.Title,
-			Link:        fmt.Sprintf("http://localhost:8080/%s.html", post.Slug), // This should be configurable
-			Description: string(post.ContentSnippetHTML),
+			Link:        s.postURL(post),
+			Description: s.feedHTML(post, post.ContentSnippetHTML),
 			PubDate:     pubDate,
-			GUID:        fmt.Sprintf("http://localhost:8080/%s.html", post.Slug), // This should be configurable
+			GUID:        RSSGUID{Value: post.ID, IsPermaLink: "false"},
+			Categories:  post.Tags,
+			Enclosure:   s.rssEnclosure(post),
+		}
+		if s.Config.Feeds.FullContent() {
+			item.ContentEncoded = &RSSCDATA{Body: s.feedHTML(post, post.ContentHTML)}
+		}
+		for _, author := range post.Authors {
+			item.Creators = append(item.Creators, author.Name)
 		}
 
 		channel.Items = append(channel.Items, item)
 	}
 
-	rss := &RSSFeed{
-		Version: "2.0",
-		Channel: channel,
-	}
-
-	output, err := xml.MarshalIndent(rss, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	filePath := filepath.Join(s.OutputDir, "rss.xml")
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+	if !lastBuild.IsZero() {
+		channel.LastBuildDate = lastBuild.Format(time.RFC1123Z)
 	}
-	defer file.Close()
 
-	// Add XML header
-	_, err = file.WriteString(xml.Header)
-	if err != nil {
-		return err
-	}
-
-	_, err = file.Write(output)
-	if err != nil {
-		return err
+	rss := &RSSFeed{
+		Version:      "2.0",
+		XmlnsDC:      "http://purl.org/dc/elements/1.1/",
+		XmlnsContent: "http://purl.org/rss/1.0/modules/content/",
+		XmlnsAtom:    "http://www.w3.org/2005/Atom",
+		Channel:      channel,
 	}
 
-	fmt.Printf("Generated RSS feed: %s\n", filePath)
-	return nil
+	return s.writeXMLFeed(rss, name, "RSS")
 }
 
 // generateAtomFeed creates an Atom feed from blog posts
 func (s *StaticSiteGenerator) generateAtomFeed(b *blog.Blog) error {
+	return s.writeAtomFeed(b.Posts, "atom.xml", s.Config.Site.Title, nil)
+}
+
+// writeAtomFeed writes an Atom feed of the published posts to name in the
+// output directory. author, if set, is the author of the whole feed.
+func (s *StaticSiteGenerator) writeAtomFeed(posts []blog.Post, name, title string, author *blog.Author) error {
+	site := s.Config.Site
 	feed := &AtomFeed{
 		Xmlns:    "http://www.w3.org/2005/Atom",
-		Title:    "My Blog", // This should be configurable
-		Subtitle: "A blog about technology and programming",
-		ID:       "http://localhost:8080", // This should be configurable
+		Title:    title,
+		Subtitle: site.Description,
+		ID:       s.absoluteURL(name),
+	}
+	if author != nil {
+		feed.Author = atomAuthor(*author)
 	}
 
 	// Add entries for each post
-	for _, post := range b.Posts {
-		if !post.Published {
-			continue
-		}
-
-		updated := post.UpdatedDate.Format(time.RFC3339)
-		if feed.Updated == "" {
-			feed.Updated = updated
+	var updated time.Time
+	for _, post := range s.feedPosts(posts) {
+		// Posts are ordered by creation date, so the most recent update can
+		// be on any entry
+		if post.UpdatedDate.After(updated) {
+			updated = post.UpdatedDate
 		}
 
 		entry := AtomEntry{
 			Title:   post.Title,
-			ID:      fmt.Sprintf("http://localhost:8080/%s.html", post.Slug),                 // This should be configurable
-			Link:    AtomLink{Href: fmt.Sprintf("http://localhost:8080/%s.html", post.Slug)}, // This should be configurable
-			Updated: updated,
-			Summary: string(post.ContentSnippetHTML),
-			Content: AtomContent{
+			ID:      post.ID,
+			Link:    AtomLink{Href: s.postURL(post)},
+			Updated: post.UpdatedDate.Format(time.RFC3339),
+			Summary: s.feedHTML(post, post.ContentSnippetHTML),
+		}
+		if s.Config.Feeds.FullContent() {
+			entry.Content = &AtomContent{
 				Type: "html",
-				Body: string(post.ContentHTML),
-			},
+				Body: s.feedHTML(post, post.ContentHTML),
+			}
+		}
+		for _, a := range post.Authors {
+			entry.Authors = append(entry.Authors, *atomAuthor(a))
 		}
 
 		feed.Entries = append(feed.Entries, entry)
 	}
 
-	output, err := xml.MarshalIndent(feed, "", "  ")
-	if err != nil {
-		return err
+	if !updated.IsZero() {
+		feed.Updated = updated.Format(time.RFC3339)
 	}
 
-	filePath := filepath.Join(s.OutputDir, "atom.xml")
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+	// Atom requires an author on the feed or on every entry
+	if feed.Author == nil {
+		for _, entry := range feed.Entries {
+			if len(entry.Authors) == 0 {
+				feed.Author = &AtomAuthor{Name: site.Title}
+				break
+			}
+		}
 	}
-	defer file.Close()
 
-	// Add XML header
-	_, err = file.WriteString(xml.Header)
+	return s.writeXMLFeed(feed, name, "Atom")
+}
+
+// feedPosts returns the published posts to include in a feed, capped at the
+// configured limit.
+func (s *StaticSiteGenerator) feedPosts(posts []blog.Post) []blog.Post {
+	var published []blog.Post
+	for _, post := range posts {
+		if !post.Published {
+			continue
+		}
+		if limit := s.Config.Feeds.Limit; limit > 0 && len(published) == limit {
+			break
+		}
+		published = append(published, post)
+	}
+	return published
+}
+
+// feedHTML returns post HTML for embedding in a feed, with every link and
+// image source resolved against the post's absolute URL so they keep working
+// in feed readers.
+func (s *StaticSiteGenerator) feedHTML(post blog.Post, content template.HTML) string {
+	return absolutizeHTML(string(content), s.postURL(post))
+}
+
+// rssEnclosure returns the enclosure for a post: its audio attachment if it
+// has one, otherwise its cover image. Posts with neither have no enclosure.
+func (s *StaticSiteGenerator) rssEnclosure(post blog.Post) *RSSEnclosure {
+	if post.Audio != nil {
+		return s.enclosure(post.Audio.File, post.Audio.Size)
+	}
+	if post.Image != "" {
+		return s.enclosure(post.Image, 0)
+	}
+	return nil
+}
+
+// enclosure returns an RSS enclosure for the file at path. A size of 0 is
+// looked up from local files in the output directory, which hold the bytes
+// served after images are re-encoded; the size of remote files is unknown,
+// which RSS expresses as a length of 0.
+func (s *StaticSiteGenerator) enclosure(path string, size int64) *RSSEnclosure {
+	if size == 0 && !strings.Contains(path, "://") {
+		if info, err := os.Stat(filepath.Join(s.OutputDir, filepath.FromSlash(path))); err == nil {
+			size = info.Size()
+		}
+	}
+
+	return &RSSEnclosure{
+		URL:    s.absoluteURL(path),
+		Length: size,
+		Type:   manifest.ContentType(path),
+	}
+}
+
+// atomAuthor converts an author profile into an Atom author element.
+func atomAuthor(author blog.Author) *AtomAuthor {
+	return &AtomAuthor{
+		Name:  author.Name,
+		URI:   author.URL(),
+		Email: author.Email,
+	}
+}
+
+// writeXMLFeed marshals feed and writes it with an XML header to name in the
+// output directory. kind names the feed format in the progress output.
+func (s *StaticSiteGenerator) writeXMLFeed(feed any, name, kind string) error {
+	output, err := xml.MarshalIndent(feed, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	_, err = file.Write(output)
-	if err != nil {
+	// Add XML header
+	filePath := filepath.Join(s.OutputDir, name)
+	if err := s.writeOutput(name, append([]byte(xml.Header), output...)); err != nil {
 		return err
 	}
 
-	fmt.Printf("Generated Atom feed: %s\n", filePath)
+	fmt.Printf("Generated %s feed: %s\n", kind, filePath)
 	return nil
 }
+
+// postURL returns the absolute URL of a post page.
+func (s *StaticSiteGenerator) postURL(post blog.Post) string {
+	return s.absoluteURL(post.Slug + ".html")
+}
+
+// absoluteURL returns the absolute URL of a path relative to the site root.
+// Paths that already are absolute URLs are returned unchanged.
+func (s *StaticSiteGenerator) absoluteURL(path string) string {
+	if strings.Contains(path, "://") {
+		return path
+	}
+	return s.Config.Site.BaseURL + "/" + strings.TrimPrefix(path, "/")
+}
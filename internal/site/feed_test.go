@@ -0,0 +1,46 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+func TestEnclosureLength(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Site.BaseURL = "https://example.com"
+	s := NewStaticSiteGenerator(filepath.Join(dir, "posts"), filepath.Join(dir, "public"), cfg)
+
+	// The served image was re-encoded smaller than its source
+	for name, size := range map[string]int{"posts/images/cover.png": 1000, "public/images/cover.png": 600} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+		size int64
+		want int64
+	}{
+		{"served file", "images/cover.png", 0, 600},
+		{"given size", "images/cover.png", 42, 42},
+		{"missing file", "images/none.png", 0, 0},
+		{"remote file", "https://cdn.example.com/a.mp3", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.enclosure(tt.path, tt.size).Length; got != tt.want {
+				t.Errorf("enclosure(%q, %d).Length = %d, want %d", tt.path, tt.size, got, tt.want)
+			}
+		})
+	}
+}
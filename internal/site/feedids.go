@@ -0,0 +1,42 @@
+package site
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"slices"
+
+	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
+)
+
+// assignFeedIDs sets the feed entry ID of every post without one in its
+// frontmatter and returns those posts. The ID is a tag URI (RFC 4151) built
+// from the site host, the creation date and the current slug, so it changes
+// with the slug until 'musings fix' writes it into the frontmatter.
+func assignFeedIDs(posts []blog.Post, baseURL string) []blog.Post {
+	host := baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	var assigned []blog.Post
+	for i, post := range posts {
+		if post.ID != "" {
+			continue
+		}
+		posts[i].ID = fmt.Sprintf("tag:%s,%s:/%s", host, post.CreatedDate.Format("2006-01-02"), post.Slug)
+		assigned = append(assigned, posts[i])
+	}
+	return assigned
+}
+
+// FeedIDs returns the feed entry IDs of the posts without an ID in their
+// frontmatter, keyed by source path, for 'musings fix' to write into them.
+func FeedIDs(cfg *config.Config, posts []blog.Post) map[string]string {
+	ids := make(map[string]string)
+	for _, post := range assignFeedIDs(slices.Clone(posts), cfg.Site.BaseURL) {
+		ids[filepath.Clean(post.SourcePath)] = post.ID
+	}
+	return ids
+}
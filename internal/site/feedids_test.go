@@ -0,0 +1,67 @@
+package site
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// atomEntryIDs returns the entry IDs of the Atom feed of the built site.
+func (ts *testSite) atomEntryIDs(t *testing.T) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(ts.outDir, "atom.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed AtomFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, entry := range feed.Entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func TestFeedIDsStableAcrossBuilds(t *testing.T) {
+	ts := newTestSite(t)
+	ts.writePost(t, "dated.md", "---\nCreatedDate: 2025-01-01\nPublished: true\n---\n# Dated Post\n\nText.\n")
+	ts.writePost(t, "fixed.md", "---\nID: tag:example.com,2024-06-01:/old-title\nCreatedDate: 2024-06-01\nPublished: true\n---\n# Old Title\n\nText.\n")
+	ts.build(t)
+	first := ts.atomEntryIDs(t)
+	want := []string{"tag:example.com,2025-01-01:/dated-post", "tag:example.com,2024-06-01:/old-title"}
+	if len(first) != 2 || first[0] != want[0] || first[1] != want[1] {
+		t.Fatalf("entry IDs %v, want %v", first, want)
+	}
+
+	// A retitled post keeps the ID from its frontmatter
+	ts.writePost(t, "fixed.md", "---\nID: tag:example.com,2024-06-01:/old-title\nCreatedDate: 2024-06-01\nPublished: true\n---\n# New Title\n\nText.\n")
+	ts.build(t)
+	if second := ts.atomEntryIDs(t); len(second) != 2 || second[0] != first[0] || second[1] != first[1] {
+		t.Errorf("entry IDs changed between builds: %v, then %v", first, second)
+	}
+}
+
+func TestAssignFeedIDs(t *testing.T) {
+	created := time.Date(2025, 8, 24, 23, 48, 0, 0, time.UTC)
+	posts := []blog.Post{
+		{Slug: "kept", ID: "urn:custom", CreatedDate: created},
+		{Slug: "new-post", CreatedDate: created, SourcePath: "posts/new.md"},
+	}
+
+	assigned := assignFeedIDs(posts, "https://blog.example.com/sub")
+	if posts[0].ID != "urn:custom" {
+		t.Errorf("frontmatter ID replaced by %q", posts[0].ID)
+	}
+	if want := "tag:blog.example.com,2025-08-24:/new-post"; posts[1].ID != want {
+		t.Errorf("assigned ID %q, want %q", posts[1].ID, want)
+	}
+	if len(assigned) != 1 || assigned[0].SourcePath != "posts/new.md" {
+		t.Errorf("assigned %v", assigned)
+	}
+}
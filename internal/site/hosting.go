@@ -0,0 +1,304 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// NotFoundData holds the data for the 404 page.
+type NotFoundData struct {
+	Base  string      // Path of the site root, which the links of the page are relative to
+	Posts []blog.Post // Latest posts, suggested instead of the missing page
+}
+
+// Redirect is a permanent redirect from an earlier path of a page to its
+// current one. Both are absolute paths on the host.
+type Redirect struct {
+	From string
+	To   string
+}
+
+// pagesPattern and assetsPattern match the paths that deploy.CacheControl
+// gives the pages and assets Cache-Control values, for rules evaluated by
+// the host.
+const (
+	pagesPattern  = `(^|/)[^./]*$|\.(html|xml|json|txt)$`
+	assetsPattern = `\.[0-9a-f]{8}\.(css|js)$`
+)
+
+// rootPath returns the path of the site root on its host, with a trailing
+// slash, such as / or /blog/.
+func (s *StaticSiteGenerator) rootPath() string {
+	u, err := url.Parse(s.Config.Site.BaseURL)
+	if err != nil {
+		return "/"
+	}
+	return strings.TrimSuffix(u.Path, "/") + "/"
+}
+
+// generateNotFoundPage creates the 404 page, which hosts serve for any
+// missing path. A base element makes its relative links work at any depth.
+// It suggests the latest posts of the index page.
+func (s *StaticSiteGenerator) generateNotFoundPage(b *blog.Blog) error {
+	posts := publishedPosts(b.Posts)
+	if len(posts) > 5 {
+		posts = posts[:5]
+	}
+	return s.renderPage("404.html", "404.html", NotFoundData{Base: s.rootPath(), Posts: posts})
+}
+
+// redirects returns the redirects from the aliases of posts to their pages,
+// sorted by source path. Aliases that name an existing page or are claimed
+// by another post are skipped with a warning.
+func (s *StaticSiteGenerator) redirects(b *blog.Blog) []Redirect {
+	pages := make(map[string]bool, len(b.Posts))
+	for _, post := range b.Posts {
+		pages[post.Slug] = true
+	}
+
+	root := s.rootPath()
+	claimed := make(map[string]string)
+	var redirects []Redirect
+	for _, post := range b.Posts {
+		for _, alias := range post.Aliases {
+			if alias == post.Slug {
+				continue
+			}
+			if pages[alias] {
+				fmt.Fprintf(os.Stderr, "Alias %s of %s is the page of another post, skipping\n", alias, post.SourcePath)
+				continue
+			}
+			if other, ok := claimed[alias]; ok {
+				fmt.Fprintf(os.Stderr, "Alias %s of %s is already used by %s, skipping\n", alias, post.SourcePath, other)
+				continue
+			}
+			claimed[alias] = post.SourcePath
+
+			to := root + post.Slug + ".html"
+			redirects = append(redirects,
+				Redirect{From: root + alias + ".html", To: to},
+				Redirect{From: root + alias, To: to})
+		}
+	}
+
+	sort.Slice(redirects, func(i, j int) bool { return redirects[i].From < redirects[j].From })
+	return redirects
+}
+
+// siteHeaders returns the configured headers sent with every response as
+// name and value pairs sorted by name, leaving out empty values.
+func (s *StaticSiteGenerator) siteHeaders() [][2]string {
+	var headers [][2]string
+	for name, value := range s.Config.Hosting.Headers {
+		if value != "" {
+			headers = append(headers, [2]string{name, value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i][0] < headers[j][0] })
+	return headers
+}
+
+// generateHostingRules writes the redirects, headers and 404 handling of the
+// site in each format listed in the hosting.formats setting.
+func (s *StaticSiteGenerator) generateHostingRules(b *blog.Blog) error {
+	redirects := s.redirects(b)
+	for _, format := range s.Config.Hosting.Formats {
+		var err error
+		switch format {
+		case "netlify":
+			err = s.writeNetlifyRules(redirects)
+		case "nginx":
+			err = s.writeHostingFile("nginx.conf", s.nginxRules(redirects))
+		case "cloudfront":
+			var rules string
+			if rules, err = s.cloudFrontFunction(redirects); err == nil {
+				err = s.writeHostingFile("cloudfront-function.js", rules)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("error writing %s rules: %w", format, err)
+		}
+	}
+	return nil
+}
+
+// writeNetlifyRules writes _redirects and _headers files to the output
+// directory. Only fingerprinted assets get a Cache-Control header, as the
+// format has no way to match the other kinds of file.
+func (s *StaticSiteGenerator) writeNetlifyRules(redirects []Redirect) error {
+	var rules strings.Builder
+	rules.WriteString("# Generated by musings publish\n")
+	for _, r := range redirects {
+		fmt.Fprintf(&rules, "%s %s 301\n", r.From, r.To)
+	}
+	if err := s.writeOutput("_redirects", []byte(rules.String())); err != nil {
+		return err
+	}
+
+	var headers strings.Builder
+	headers.WriteString("# Generated by musings publish\n")
+	root := s.rootPath()
+	fmt.Fprintf(&headers, "%s*\n", root)
+	for _, h := range s.siteHeaders() {
+		fmt.Fprintf(&headers, "  %s: %s\n", h[0], h[1])
+	}
+
+	var assets []string
+	fingerprinted := regexp.MustCompile(assetsPattern)
+	for name := range s.written {
+		if fingerprinted.MatchString(name) {
+			assets = append(assets, name)
+		}
+	}
+	sort.Strings(assets)
+	if cc := s.Config.Hosting.CacheControl.Assets; cc != "" {
+		for _, name := range assets {
+			fmt.Fprintf(&headers, "%s%s\n  Cache-Control: %s\n", root, name, cc)
+		}
+	}
+	if err := s.writeOutput("_headers", []byte(headers.String())); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated redirects and headers: %s, %s\n", filepath.Join(s.OutputDir, "_redirects"), filepath.Join(s.OutputDir, "_headers"))
+	return nil
+}
+
+// nginxRules returns an nginx configuration include with the locations of
+// the site.
+func (s *StaticSiteGenerator) nginxRules(redirects []Redirect) string {
+	var rules strings.Builder
+	rules.WriteString(`# Generated by musings publish. Include this file in the server block that
+# serves the site; it defines the locations for the whole site, so the server
+# block only needs listen, server_name, root and TLS settings.
+
+`)
+	fmt.Fprintf(&rules, "error_page 404 %s404.html;\n", s.rootPath())
+
+	if len(redirects) > 0 {
+		rules.WriteString("\n")
+	}
+	for _, r := range redirects {
+		fmt.Fprintf(&rules, "location = %s { return 301 %s; }\n", nginxQuote(r.From), nginxQuote(r.To))
+	}
+
+	// A location with add_header directives does not inherit those of the
+	// server block, so every location repeats the site headers
+	cc := s.Config.Hosting.CacheControl
+	for _, loc := range []struct{ match, cacheControl string }{
+		{"~ " + nginxQuote(assetsPattern), cc.Assets},
+		{"~* " + nginxQuote(pagesPattern), cc.Pages},
+		{"/", cc.Default},
+	} {
+		fmt.Fprintf(&rules, "\nlocation %s {\n", loc.match)
+		if loc.cacheControl != "" {
+			fmt.Fprintf(&rules, "    add_header Cache-Control %s always;\n", nginxQuote(loc.cacheControl))
+		}
+		for _, h := range s.siteHeaders() {
+			fmt.Fprintf(&rules, "    add_header %s %s always;\n", h[0], nginxQuote(h[1]))
+		}
+		rules.WriteString("}\n")
+	}
+	return rules.String()
+}
+
+// nginxQuote returns v as a double-quoted nginx string. Backslashes other
+// than before a quote are kept as written, so regular expressions need no
+// escaping.
+func nginxQuote(v string) string {
+	return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+}
+
+// cloudFrontFunction returns the code of a CloudFront Function that
+// redirects aliases and adds the site headers. The same function handles
+// viewer requests and viewer responses.
+func (s *StaticSiteGenerator) cloudFrontFunction(redirects []Redirect) (string, error) {
+	locations := make(map[string]string, len(redirects))
+	for _, r := range redirects {
+		locations[r.From] = r.To
+	}
+	headers := make(map[string]string)
+	for _, h := range s.siteHeaders() {
+		headers[strings.ToLower(h[0])] = h[1]
+	}
+	cc := s.Config.Hosting.CacheControl
+
+	values := make([]string, 0, 5)
+	for _, v := range []any{locations, headers, cc.Assets, cc.Pages, cc.Default} {
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		values = append(values, string(data))
+	}
+
+	return fmt.Sprintf(`// Generated by musings publish. Associate this CloudFront Function with both
+// the viewer request and the viewer response events of the distribution.
+
+var redirects = %s;
+
+var headers = %s;
+
+function cacheControl(uri) {
+    if (/%s/.test(uri)) {
+        return %s;
+    }
+    if (/%s/i.test(uri)) {
+        return %s;
+    }
+    return %s;
+}
+
+function handler(event) {
+    var request = event.request;
+
+    if (event.context.eventType === "viewer-request") {
+        var location = redirects[request.uri];
+        if (location) {
+            return {
+                statusCode: 301,
+                statusDescription: "Moved Permanently",
+                headers: { location: { value: location } }
+            };
+        }
+        if (/\/$/.test(request.uri)) {
+            request.uri += "index.html";
+        }
+        return request;
+    }
+
+    var response = event.response;
+    for (var name in headers) {
+        response.headers[name] = { value: headers[name] };
+    }
+    var value = cacheControl(request.uri);
+    if (value) {
+        response.headers["cache-control"] = { value: value };
+    }
+    return response;
+}
+`, values[0], values[1], strings.ReplaceAll(assetsPattern, "/", `\/`), values[2],
+		strings.ReplaceAll(pagesPattern, "/", `\/`), values[3], values[4]), nil
+}
+
+// writeHostingFile writes a rule file that is not part of the site to the
+// hosting.dir directory.
+func (s *StaticSiteGenerator) writeHostingFile(name, content string) error {
+	if err := os.MkdirAll(s.Config.Hosting.Dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Config.Hosting.Dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Generated hosting rules: %s\n", path)
+	return nil
+}
@@ -0,0 +1,278 @@
+package site
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// imageVariant describes one rendition of a source image in the output directory.
+type imageVariant struct {
+	Path  string // Path relative to the output directory, e.g. images/photo-480w.png
+	Width int    // Width of the rendition in pixels
+}
+
+// imgSrcPattern matches the src attribute of img tags produced by the markdown renderer.
+var imgSrcPattern = regexp.MustCompile(`<img src="([^"]+)"`)
+
+// processImages copies the images directory from posts to the output directory.
+// JPEG and PNG images are re-encoded with the configured quality settings and
+// resized to the configured widths. Renditions are cached by source content and
+// settings so unchanged images are not reprocessed on the next build.
+func (s *StaticSiteGenerator) processImages() error {
+	src := filepath.Join(s.PostsDir, "images")
+	dst := filepath.Join(s.OutputDir, "images")
+
+	s.images = make(map[string][]imageVariant)
+
+	// Check if source directory exists
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		// No images directory, nothing to copy
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.Config.Images.CacheDir, 0755); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip directories
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(dst, relPath)
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return err
+		}
+
+		if !isResizableImage(path) {
+			return s.copyFile(path, dstPath)
+		}
+
+		variants, err := s.resizeImage(path, filepath.ToSlash(filepath.Join("images", relPath)))
+		if err != nil {
+			return fmt.Errorf("error processing image %s: %w", path, err)
+		}
+		s.images[filepath.ToSlash(filepath.Join("images", relPath))] = variants
+		return nil
+	})
+}
+
+// resizeImage writes the image at srcPath and its resized variants to the output
+// directory and returns all renditions, including the full-size one, ordered by
+// width. relPath is the image path relative to the output directory. The full
+// size image is re-encoded with the quality settings, but the original is kept
+// when it is smaller.
+func (s *StaticSiteGenerator) resizeImage(srcPath, relPath string) ([]imageVariant, error) {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	variants := []imageVariant{{Path: relPath, Width: cfg.Width}}
+	key := s.imageCacheKey(data)
+	ext := filepath.Ext(relPath)
+	base := strings.TrimSuffix(relPath, ext)
+
+	// Decode lazily so fully cached images are never decoded
+	var img image.Image
+	decode := func() error {
+		if img != nil {
+			return nil
+		}
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		img = decoded
+		return err
+	}
+
+	fullPath := filepath.Join(s.Config.Images.CacheDir, key+"-full"+ext)
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		if err := decode(); err != nil {
+			return nil, err
+		}
+		if err := s.encodeImage(img, fullPath); err != nil {
+			return nil, err
+		}
+		if info, err = os.Stat(fullPath); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Re-encoded image: %s (%d to %d bytes)\n", relPath, len(data), info.Size())
+	} else if err != nil {
+		return nil, err
+	}
+	full := srcPath
+	if info.Size() < int64(len(data)) {
+		full = fullPath
+	}
+	if err := s.copyFile(full, filepath.Join(s.OutputDir, filepath.FromSlash(relPath))); err != nil {
+		return nil, err
+	}
+
+	for _, width := range s.Config.Images.Widths {
+		// Never upscale; the original already covers larger viewports
+		if width >= cfg.Width {
+			continue
+		}
+
+		variant := imageVariant{
+			Path:  fmt.Sprintf("%s-%dw%s", base, width, ext),
+			Width: width,
+		}
+		cachePath := filepath.Join(s.Config.Images.CacheDir, fmt.Sprintf("%s-%dw%s", key, width, ext))
+
+		if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+			if err := decode(); err != nil {
+				return nil, err
+			}
+			if err := s.writeResized(img, width, cachePath); err != nil {
+				return nil, err
+			}
+			fmt.Printf("Resized image: %s (%dpx)\n", relPath, width)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := s.copyFile(cachePath, filepath.Join(s.OutputDir, filepath.FromSlash(variant.Path))); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].Width < variants[j].Width
+	})
+
+	return variants, nil
+}
+
+// writeResized scales img down to width, preserving the aspect ratio, and
+// encodes the result to path using the format implied by its extension.
+func (s *StaticSiteGenerator) writeResized(img image.Image, width int, path string) error {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+	return s.encodeImage(resized, path)
+}
+
+// encodeImage encodes img to path using the format implied by its extension
+// and the configured quality settings.
+func (s *StaticSiteGenerator) encodeImage(img image.Image, path string) error {
+	// Write to a temporary file first so an interrupted build never leaves a
+	// truncated variant in the cache
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(file, img, &jpeg.Options{Quality: s.Config.Images.JPEGQuality})
+	default:
+		encoder := png.Encoder{CompressionLevel: pngCompressionLevel(s.Config.Images.PNGLevel)}
+		err = encoder.Encode(file, img)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// imageCacheKey derives the cache key for an image from its content and the
+// encoding settings, so changing either invalidates the cached variants.
+func (s *StaticSiteGenerator) imageCacheKey(data []byte) string {
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "|q=%d|png=%s", s.Config.Images.JPEGQuality, s.Config.Images.PNGLevel)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// responsiveImages adds srcset and sizes attributes to every img tag in content
+// that refers to an image with resized variants.
+func (s *StaticSiteGenerator) responsiveImages(content template.HTML) template.HTML {
+	if len(s.images) == 0 {
+		return content
+	}
+
+	result := imgSrcPattern.ReplaceAllStringFunc(string(content), func(tag string) string {
+		src := imgSrcPattern.FindStringSubmatch(tag)[1]
+		variants, ok := s.images[src]
+		if !ok || len(variants) < 2 {
+			return tag
+		}
+
+		candidates := make([]string, len(variants))
+		for i, v := range variants {
+			candidates[i] = v.Path + " " + strconv.Itoa(v.Width) + "w"
+		}
+
+		return fmt.Sprintf(`%s srcset="%s" sizes="%s"`, tag,
+			strings.Join(candidates, ", "), template.HTMLEscapeString(s.Config.Images.Sizes))
+	})
+
+	return template.HTML(result)
+}
+
+// isResizableImage reports whether the file at path is an image format the
+// pipeline can decode and re-encode.
+func isResizableImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// pngCompressionLevel maps the configured PNG level name to an encoder setting.
+func pngCompressionLevel(level string) png.CompressionLevel {
+	switch level {
+	case "none":
+		return png.NoCompression
+	case "speed":
+		return png.BestSpeed
+	case "best":
+		return png.BestCompression
+	}
+	return png.DefaultCompression
+}
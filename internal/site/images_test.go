@@ -0,0 +1,186 @@
+package site
+
+import (
+	"bytes"
+	"html/template"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+// testImage returns a width by height image with a pattern that compresses
+// differently at each PNG compression level.
+func testImage(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 7), uint8(y * 13), uint8(x * y), 255})
+		}
+	}
+	return img
+}
+
+// writeTestImage encodes a generated image to a file in dir and returns its
+// path. PNG files use level, JPEG files the given quality.
+func writeTestImage(t *testing.T, dir, name string, level png.CompressionLevel, quality int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	img := testImage(32, 24)
+	if filepath.Ext(name) == ".png" {
+		err = (&png.Encoder{CompressionLevel: level}).Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newImageGenerator returns a generator with temporary output and image
+// cache directories.
+func newImageGenerator(t *testing.T) *StaticSiteGenerator {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Images.CacheDir = filepath.Join(dir, "cache")
+	if err := os.MkdirAll(cfg.Images.CacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "public", "images"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewStaticSiteGenerator(filepath.Join(dir, "posts"), filepath.Join(dir, "public"), cfg)
+}
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name         string
+		srcLevel     png.CompressionLevel // Compression of the source file
+		pngLevel     string               // Configured compression of re-encoded files
+		widths       []int
+		wantWidths   []int
+		wantOriginal bool // Whether the full-size output is the source file
+	}{
+		{"re-encoded is smaller", png.NoCompression, "best", []int{8, 16}, []int{8, 16, 32}, false},
+		{"original is smaller", png.BestCompression, "none", []int{8, 16}, []int{8, 16, 32}, true},
+		{"never upscales", png.BestCompression, "best", []int{16, 32, 64}, []int{16, 32}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newImageGenerator(t)
+			s.Config.Images.PNGLevel = tt.pngLevel
+			s.Config.Images.Widths = tt.widths
+			src := writeTestImage(t, t.TempDir(), "photo.png", tt.srcLevel, 0)
+
+			variants, err := s.resizeImage(src, "images/photo.png")
+			if err != nil {
+				t.Fatalf("resizeImage: %v", err)
+			}
+			var widths []int
+			for _, v := range variants {
+				widths = append(widths, v.Width)
+				if _, err := os.Stat(filepath.Join(s.OutputDir, filepath.FromSlash(v.Path))); err != nil {
+					t.Errorf("rendition %s not written: %v", v.Path, err)
+				}
+			}
+			if len(widths) != len(tt.wantWidths) {
+				t.Fatalf("widths %v, want %v", widths, tt.wantWidths)
+			}
+			for i := range widths {
+				if widths[i] != tt.wantWidths[i] {
+					t.Fatalf("widths %v, want %v", widths, tt.wantWidths)
+				}
+			}
+
+			original, err := os.ReadFile(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			full, err := os.ReadFile(filepath.Join(s.OutputDir, "images", "photo.png"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.Equal(full, original); got != tt.wantOriginal {
+				t.Errorf("full-size output is the original: %v, want %v", got, tt.wantOriginal)
+			}
+			if len(full) > len(original) {
+				t.Errorf("full-size output has %d bytes, more than the %d of the original", len(full), len(original))
+			}
+		})
+	}
+}
+
+func TestResizeImageQualityInvalidatesCache(t *testing.T) {
+	s := newImageGenerator(t)
+	s.Config.Images.Widths = []int{16}
+	src := writeTestImage(t, t.TempDir(), "photo.jpg", 0, 100)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resize := func(quality int) string {
+		t.Helper()
+		s.Config.Images.JPEGQuality = quality
+		if _, err := s.resizeImage(src, "images/photo.jpg"); err != nil {
+			t.Fatalf("resizeImage: %v", err)
+		}
+		key := s.imageCacheKey(data)
+		for _, name := range []string{key + "-full.jpg", key + "-16w.jpg"} {
+			if _, err := os.Stat(filepath.Join(s.Config.Images.CacheDir, name)); err != nil {
+				t.Errorf("quality %d: %s not cached: %v", quality, name, err)
+			}
+		}
+		return key
+	}
+
+	first := resize(80)
+	if again := resize(80); again != first {
+		t.Errorf("cache key changed from %s to %s with the same settings", first, again)
+	}
+	if lower := resize(30); lower == first {
+		t.Errorf("cache key %s kept after changing the JPEG quality", lower)
+	}
+}
+
+func TestResponsiveImages(t *testing.T) {
+	s := NewStaticSiteGenerator("posts", "public", config.Default())
+	s.Config.Images.Sizes = "(max-width: 800px) 100vw, 800px"
+	s.images = map[string][]imageVariant{
+		"images/photo.png": {{"images/photo-480w.png", 480}, {"images/photo-800w.png", 800}, {"images/photo.png", 1600}},
+		"images/small.png": {{"images/small.png", 300}},
+	}
+
+	tests := []struct {
+		name    string
+		content template.HTML
+		want    template.HTML
+	}{
+		{
+			"resized image",
+			`<p><img src="images/photo.png" alt="A photo"></p>`,
+			`<p><img src="images/photo.png" srcset="images/photo-480w.png 480w, images/photo-800w.png 800w, images/photo.png 1600w" sizes="(max-width: 800px) 100vw, 800px" alt="A photo"></p>`,
+		},
+		{"image without variants", `<img src="images/small.png" alt="">`, `<img src="images/small.png" alt="">`},
+		{"unknown image", `<img src="https://example.com/a.png" alt="">`, `<img src="https://example.com/a.png" alt="">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.responsiveImages(tt.content); got != tt.want {
+				t.Errorf("responsiveImages(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
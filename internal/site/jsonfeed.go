@@ -0,0 +1,121 @@
+package site
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// JSONFeed represents a JSON Feed (https://jsonfeed.org/version/1.1)
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []JSONFeedAuthor `json:"authors,omitempty"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+// JSONFeedAuthor represents a JSON Feed author
+type JSONFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// JSONFeedItem represents a JSON Feed item
+type JSONFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Authors       []JSONFeedAuthor `json:"authors,omitempty"`
+}
+
+// generateJSONFeed creates a JSON feed from blog posts
+func (s *StaticSiteGenerator) generateJSONFeed(b *blog.Blog) error {
+	return s.writeJSONFeed(b.Posts, "feed.json", s.Config.Site.Title, nil)
+}
+
+// writeJSONFeed writes a JSON feed of the published posts to name in the
+// output directory. author, if set, is the author of the whole feed.
+func (s *StaticSiteGenerator) writeJSONFeed(posts []blog.Post, name, title string, author *blog.Author) error {
+	site := s.Config.Site
+	feed := &JSONFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       title,
+		HomePageURL: site.BaseURL + "/",
+		FeedURL:     s.absoluteURL(name),
+		Description: site.Description,
+		Language:    site.Language,
+		Items:       make([]JSONFeedItem, 0),
+	}
+	if author != nil {
+		feed.Authors = []JSONFeedAuthor{s.jsonFeedAuthor(*author)}
+	}
+
+	// Add items for each post
+	for _, post := range s.feedPosts(posts) {
+		item := JSONFeedItem{
+			ID:            post.ID,
+			URL:           s.postURL(post),
+			Title:         post.Title,
+			ContentHTML:   s.feedHTML(post, post.ContentHTML),
+			Summary:       post.ContentSnippet,
+			DatePublished: post.CreatedDate.Format(time.RFC3339),
+			DateModified:  post.UpdatedDate.Format(time.RFC3339),
+			Tags:          post.Tags,
+		}
+		if !s.Config.Feeds.FullContent() {
+			item.ContentHTML = s.feedHTML(post, post.ContentSnippetHTML)
+		}
+		if post.Image != "" {
+			item.Image = s.absoluteURL(post.Image)
+		}
+		for _, a := range post.Authors {
+			item.Authors = append(item.Authors, s.jsonFeedAuthor(a))
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	// Keep the embedded HTML readable instead of escaping <, > and &
+	var output bytes.Buffer
+	encoder := json.NewEncoder(&output)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(s.OutputDir, name)
+	if err := s.writeOutput(name, output.Bytes()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated JSON feed: %s\n", filePath)
+	return nil
+}
+
+// jsonFeedAuthor converts an author profile into a JSON Feed author.
+func (s *StaticSiteGenerator) jsonFeedAuthor(author blog.Author) JSONFeedAuthor {
+	a := JSONFeedAuthor{
+		Name: author.Name,
+		URL:  author.URL(),
+	}
+	if author.Avatar != "" {
+		a.Avatar = s.absoluteURL(author.Avatar)
+	}
+	return a
+}
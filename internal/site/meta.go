@@ -0,0 +1,130 @@
+package site
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// metaDescriptionLength is the maximum length of generated page descriptions.
+const metaDescriptionLength = 160
+
+// PageMeta holds the metadata rendered into the head of every page by the
+// "meta" template: description, canonical link, Open Graph and Twitter Card
+// tags and optional JSON-LD structured data.
+type PageMeta struct {
+	Title       string
+	Description string
+	Canonical   string      // Absolute URL of the page
+	Type        string      // Open Graph type, e.g. website or article
+	Image       string      // Absolute URL of the share image, empty if none
+	SiteName    string      // Site title
+	Twitter     string      // Twitter handle of the site
+	Creator     string      // Twitter handle of the author
+	Published   string      // RFC 3339 publication time, articles only
+	Modified    string      // RFC 3339 modification time, articles only
+	Authors     []string    // Author names, articles only
+	Tags        []string    // Article tags
+	JSONLD      template.JS // Structured data, empty if none
+}
+
+// TwitterCard returns the Twitter Card type for the page.
+func (m PageMeta) TwitterCard() string {
+	if m.Image != "" {
+		return "summary_large_image"
+	}
+	return "summary"
+}
+
+// pageMeta returns the metadata for a non-article page at path, relative to
+// the site root, using the site defaults.
+func (s *StaticSiteGenerator) pageMeta(title, description, path string) PageMeta {
+	site := s.Config.Site
+	meta := PageMeta{
+		Title:       title,
+		Description: fallback(description, site.Description),
+		Canonical:   s.absoluteURL(path),
+		Type:        "website",
+		SiteName:    site.Title,
+		Twitter:     site.Twitter,
+	}
+	if site.Image != "" {
+		meta.Image = s.absoluteURL(site.Image)
+	}
+	return meta
+}
+
+// postMeta returns the metadata for a post page, including BlogPosting
+// JSON-LD. Values the post does not provide fall back to the site defaults.
+func (s *StaticSiteGenerator) postMeta(post blog.Post) PageMeta {
+	description := post.Description
+	if description == "" {
+		description = excerpt(post.PlainText(), metaDescriptionLength)
+	}
+
+	meta := s.pageMeta(post.Title, description, post.Slug+".html")
+	meta.Type = "article"
+	meta.Published = post.CreatedDate.Format(time.RFC3339)
+	meta.Modified = post.UpdatedDate.Format(time.RFC3339)
+	meta.Tags = post.Tags
+	if post.Image != "" {
+		meta.Image = s.absoluteURL(post.Image)
+	} else if s.Config.Cards.Enabled && post.Published {
+		meta.Image = s.absoluteURL(cardImageName(post))
+	}
+	for _, author := range post.Authors {
+		meta.Authors = append(meta.Authors, author.Name)
+		if meta.Creator == "" && author.Twitter != "" {
+			meta.Creator = author.Twitter
+		}
+	}
+
+	meta.JSONLD = s.blogPostingJSONLD(post, meta)
+	return meta
+}
+
+// blogPostingJSONLD returns the schema.org BlogPosting structured data for a post.
+func (s *StaticSiteGenerator) blogPostingJSONLD(post blog.Post, meta PageMeta) template.JS {
+	type thing map[string]any
+
+	authors := make([]thing, 0, len(post.Authors))
+	for _, author := range post.Authors {
+		a := thing{"@type": "Person", "name": author.Name}
+		if url := author.URL(); url != "" {
+			a["url"] = url
+		}
+		authors = append(authors, a)
+	}
+
+	data := thing{
+		"@context":         "https://schema.org",
+		"@type":            "BlogPosting",
+		"headline":         post.Title,
+		"description":      meta.Description,
+		"url":              meta.Canonical,
+		"mainEntityOfPage": thing{"@type": "WebPage", "@id": meta.Canonical},
+		"datePublished":    meta.Published,
+		"dateModified":     meta.Modified,
+		"wordCount":        len(strings.Fields(post.PlainText())),
+		"publisher":        thing{"@type": "Organization", "name": s.Config.Site.Title},
+	}
+	if len(authors) > 0 {
+		data["author"] = authors
+	}
+	if len(post.Tags) > 0 {
+		data["keywords"] = strings.Join(post.Tags, ", ")
+	}
+	if meta.Image != "" {
+		data["image"] = meta.Image
+	}
+
+	// json.Marshal escapes <, > and &, so the output cannot close the script element
+	output, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return template.JS(output)
+}
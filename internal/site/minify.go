@@ -0,0 +1,435 @@
+package site
+
+import (
+	"strings"
+)
+
+// minifyCSS removes comments and the whitespace CSS does not need. Strings
+// are copied unchanged. Whitespace before a colon is only removed in
+// declarations, since in a selector "article :hover" differs from
+// "article:hover".
+func minifyCSS(src string) string {
+	out := make([]byte, 0, len(src))
+
+	space := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return string(out)
+			}
+			i += end + 3
+		case c == '"' || c == '\'':
+			if space && !strings.ContainsRune("{};,>:", rune(out[len(out)-1])) {
+				out = append(out, ' ')
+			}
+			space = false
+			end := stringEnd(src, i)
+			out = append(out, src[i:end]...)
+			i = end - 1
+		case isSpace(c):
+			space = len(out) > 0
+		default:
+			tight := strings.ContainsRune("{};,>", rune(c)) || c == ':' && !inSelector(src, i)
+			if space && !tight && !strings.ContainsRune("{};,>:", rune(out[len(out)-1])) {
+				out = append(out, ' ')
+			}
+			space = false
+			if c == '}' && len(out) > 0 && out[len(out)-1] == ';' {
+				out = out[:len(out)-1]
+			}
+			out = append(out, c)
+		}
+	}
+
+	return string(out)
+}
+
+// inSelector reports whether position i of the stylesheet src is in a
+// selector or at-rule prelude rather than a declaration, that is whether a
+// block opens before the current statement ends.
+func inSelector(src string, i int) bool {
+	for ; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			return true
+		case ';', '}':
+			return false
+		case '"', '\'':
+			i = stringEnd(src, i) - 1
+		}
+	}
+	return false
+}
+
+// jsTightPunctuation lists the characters minifyJS removes spaces around.
+// Operators are left alone so that "a - -b" or "a + +b" keep their meaning.
+const jsTightPunctuation = "{}()[];,:="
+
+// jsRegexKeywords lists the keywords after which a slash starts a regular
+// expression rather than a division.
+var jsRegexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "of": true, "new": true, "delete": true, "void": true,
+	"throw": true, "instanceof": true, "yield": true, "await": true,
+}
+
+// minifyJS removes comments, indentation, blank lines and the spaces around
+// punctuation from JavaScript. Line breaks are kept so automatic semicolon
+// insertion works as in the source, and strings, template literals and
+// regular expressions are copied unchanged.
+func minifyJS(src string) string {
+	out := make([]byte, 0, len(src))
+
+	space, newline := false, false
+	emit := func(s string) {
+		switch {
+		case len(out) == 0:
+		case newline:
+			out = append(out, '\n')
+		case space && !strings.ContainsRune(jsTightPunctuation, rune(s[0])) && !strings.ContainsRune(jsTightPunctuation, rune(out[len(out)-1])):
+			out = append(out, ' ')
+		}
+		space, newline = false, false
+		out = append(out, s...)
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				return string(out)
+			}
+			i += end - 1
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return string(out)
+			}
+			space = true
+			i += end + 3
+		case c == '"' || c == '\'':
+			end := stringEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '`':
+			end := templateEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '/' && regexAllowed(out):
+			end := regexEnd(src, i)
+			emit(src[i:end])
+			i = end - 1
+		case c == '\n':
+			newline = len(out) > 0
+		case isSpace(c):
+			space = true
+		default:
+			emit(src[i : i+1])
+		}
+	}
+
+	return string(out)
+}
+
+// regexAllowed reports whether a slash following the minified output so far
+// starts a regular expression literal.
+func regexAllowed(out []byte) bool {
+	end := len(out)
+	for end > 0 && isSpace(out[end-1]) {
+		end--
+	}
+	if end >= 2 && (string(out[end-2:end]) == "++" || string(out[end-2:end]) == "--") {
+		// Postfix increments end an operand, as in "i++ / 2"
+		return false
+	}
+	if end == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^", out[end-1]) >= 0 {
+		return true
+	}
+
+	word := end
+	for word > 0 && isIdentByte(out[word-1]) {
+		word--
+	}
+	return jsRegexKeywords[string(out[word:end])]
+}
+
+// regexEnd returns the index just past the regular expression literal and
+// its flags starting at src[start].
+func regexEnd(src string, start int) int {
+	class := false
+	i := start + 1
+	for ; i < len(src) && src[i] != '\n'; i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if !class {
+				i++
+				for i < len(src) && isIdentByte(src[i]) {
+					i++
+				}
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// htmlBlockTags lists elements around which whitespace never renders, so
+// minifyHTML can drop it entirely.
+var htmlBlockTags = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true,
+	"link": true, "script": true, "style": true, "div": true, "p": true,
+	"ul": true, "ol": true, "li": true, "nav": true, "header": true,
+	"footer": true, "main": true, "section": true, "article": true,
+	"aside": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "table": true, "thead": true, "tbody": true, "tr": true,
+	"td": true, "th": true, "form": true, "figure": true, "figcaption": true,
+	"br": true, "hr": true, "pre": true, "blockquote": true, "dl": true,
+	"dt": true, "dd": true, "path": true, "circle": true, "!doctype": true,
+	"picture": true, "source": true, "audio": true,
+}
+
+// htmlRawTags lists elements whose content minifyHTML leaves as it is,
+// except for style sheets which are minified as CSS.
+var htmlRawTags = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true,
+}
+
+// htmlToken is a tag or a run of text in an HTML document.
+type htmlToken struct {
+	text string
+	name string // Lowercase element name for tags, empty for text
+}
+
+// minifyHTML removes comments and collapses whitespace in an HTML document.
+// Whitespace next to block-level elements is dropped, other runs become a
+// single space; the content of pre, textarea and script elements is kept.
+func minifyHTML(src string) string {
+	tokens := tokenizeHTML(src)
+
+	var out strings.Builder
+	out.Grow(len(src))
+
+	for i, tok := range tokens {
+		if tok.name != "" {
+			out.WriteString(tok.text)
+			continue
+		}
+		if i > 0 && htmlRawTags[tokens[i-1].name] && !strings.HasPrefix(tokens[i-1].text, "</") {
+			if tokens[i-1].name == "style" {
+				out.WriteString(minifyCSS(tok.text))
+			} else {
+				out.WriteString(tok.text)
+			}
+			continue
+		}
+
+		text := collapseSpace(tok.text)
+		if i == 0 || htmlBlockTags[tokens[i-1].name] {
+			text = strings.TrimLeft(text, " ")
+		}
+		if i == len(tokens)-1 || htmlBlockTags[tokens[i+1].name] {
+			text = strings.TrimRight(text, " ")
+		}
+		out.WriteString(text)
+	}
+
+	return out.String()
+}
+
+// tokenizeHTML splits src into tags and text, dropping comments. The content
+// of raw text elements is returned as a single text token.
+func tokenizeHTML(src string) []htmlToken {
+	var tokens []htmlToken
+	for len(src) > 0 {
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+
+		if src[0] != '<' || len(src) < 2 || !(isIdentByte(src[1]) || src[1] == '/' || src[1] == '!') {
+			end := strings.IndexByte(src[1:], '<')
+			if end < 0 {
+				end = len(src) - 1
+			}
+			tokens = append(tokens, htmlToken{text: src[:end+1]})
+			src = src[end+1:]
+			continue
+		}
+
+		end := tagEnd(src)
+		tag := htmlToken{text: minifyTag(src[:end]), name: tagName(src[:end])}
+		tokens = append(tokens, tag)
+		src = src[end:]
+
+		if htmlRawTags[tag.name] && !strings.HasPrefix(tag.text, "</") {
+			closing := indexClosingTag(src, tag.name)
+			tokens = append(tokens, htmlToken{text: src[:closing]})
+			src = src[closing:]
+		}
+	}
+	return tokens
+}
+
+// indexClosingTag returns the index of the first closing tag for the named
+// element in src, ignoring case, or len(src) if there is none.
+func indexClosingTag(src, name string) int {
+	for i := strings.Index(src, "</"); i >= 0 && i+2+len(name) <= len(src); {
+		if strings.EqualFold(src[i+2:i+2+len(name)], name) {
+			return i
+		}
+		next := strings.Index(src[i+2:], "</")
+		if next < 0 {
+			break
+		}
+		i += next + 2
+	}
+	return len(src)
+}
+
+// tagEnd returns the index just past the tag starting at src[0], skipping
+// over quoted attribute values.
+func tagEnd(src string) int {
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '"', '\'':
+			i = stringEnd(src, i) - 1
+		case '>':
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// tagName returns the lowercase element name of a tag.
+func tagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	end := strings.IndexFunc(name, func(r rune) bool {
+		return r == '>' || r == '/' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name)
+}
+
+// minifyTag collapses the whitespace between the attributes of a tag.
+// Attribute values are copied unchanged.
+func minifyTag(tag string) string {
+	var out strings.Builder
+	space := false
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case c == '"' || c == '\'':
+			if space {
+				out.WriteByte(' ')
+				space = false
+			}
+			end := stringEnd(tag, i)
+			out.WriteString(tag[i:end])
+			i = end - 1
+		case isSpace(c):
+			space = true
+		default:
+			if space && c != '>' && c != '/' && c != '=' && !strings.HasSuffix(out.String(), "=") {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// collapseSpace replaces every run of whitespace in s with a single space.
+func collapseSpace(s string) string {
+	var out strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if isSpace(s[i]) {
+			space = true
+			continue
+		}
+		if space {
+			out.WriteByte(' ')
+			space = false
+		}
+		out.WriteByte(s[i])
+	}
+	if space {
+		out.WriteByte(' ')
+	}
+	return out.String()
+}
+
+// stringEnd returns the index just past the quoted string starting at
+// src[start], honoring backslash escapes.
+func stringEnd(src string, start int) int {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// templateEnd returns the index just past the template literal starting at
+// src[start]. The expressions of its substitutions are skipped as a whole,
+// so strings and template literals nested in them do not end it.
+func templateEnd(src string, start int) int {
+	for i := start + 1; i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '`':
+			return i + 1
+		case src[i] == '$' && i+1 < len(src) && src[i+1] == '{':
+			depth := 0
+		expression:
+			for i++; i < len(src); i++ {
+				switch src[i] {
+				case '{':
+					depth++
+				case '}':
+					if depth--; depth == 0 {
+						break expression
+					}
+				case '"', '\'':
+					i = stringEnd(src, i) - 1
+				case '`':
+					i = templateEnd(src, i) - 1
+				}
+			}
+		}
+	}
+	return len(src)
+}
+
+// isSpace reports whether c is an ASCII whitespace character.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isIdentByte reports whether c can be part of an identifier.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
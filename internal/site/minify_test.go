@@ -0,0 +1,78 @@
+package site
+
+import "testing"
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"comments and indentation", "// setup\nvar a = 1; /* inline */ var b = 2;\n\n    f(a, b);\n", "var a=1;var b=2;\nf(a,b);"},
+		{"line breaks are kept", "let a = b\n(c || d).go()", "let a=b\n(c || d).go()"},
+		{"operators keep their spaces", "x = a - -b + +c", "x=a - -b + +c"},
+		{"strings are copied", `s = "a  // b" + 'c /* d */'`, `s="a  // b" + 'c /* d */'`},
+		{"escaped quotes", `s = "say \"hi\"  now"`, `s="say \"hi\"  now"`},
+		{"regex after assignment", "re = /a  b\\/c/gi; n = 1", "re=/a  b\\/c/gi;n=1"},
+		{"regex after paren", "s.replace(/ +/g, ' ')", "s.replace(/ +/g,' ')"},
+		{"regex after keyword", "return /x  y/.test(s)", "return /x  y/.test(s)"},
+		{"regex with slash in class", "re = /[/]  x/", "re=/[/]  x/"},
+		{"division after identifier", "x = a / b / c", "x=a / b / c"},
+		{"division after paren", "x = (a + b) / 2 / c", "x=(a + b)/ 2 / c"},
+		{"division after postfix increment", "x = i++ / 2 // half", "x=i++ / 2"},
+		{"template literal", "t = `a  ${b}  c`", "t=`a  ${b}  c`"},
+		{"nested template literal", "t = `a ${f(`b  ${c}`)}  d` + 1", "t=`a ${f(`b  ${c}`)}  d` + 1"},
+		{"template with braces and strings", "t = `${ {a: '}'}.a }  x`; y = 1", "t=`${ {a: '}'}.a }  x`;y=1"},
+		{"template with escaped backtick", "t = `a \\` b`; y = 1", "t=`a \\` b`;y=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minifyJS(tt.src); got != tt.want {
+				t.Errorf("minifyJS(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyCSS(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"rules", "/* theme */\nbody {\n  color: red;\n  margin: 0 auto;\n}\n", "body{color:red;margin:0 auto}"},
+		{"selectors", "ul  li > a,\np a:hover { x: y }", "ul li>a,p a:hover{x:y}"},
+		{"strings", `a::before { content: "  /* no */  "; }`, `a::before{content:"  /* no */  "}`},
+		{"media queries", "@media (max-width: 600px) {\n  .a { b: c; }\n}", "@media (max-width:600px){.a{b:c}}"},
+		{"spaced declarations", "a { color : red ; margin :0 }", "a{color:red;margin:0}"},
+		{"descendant pseudo-class", "article :hover { color: red }", "article :hover{color:red}"},
+		{"descendant functional pseudo-class", "a :not(.x), b ::before { c: d }", "a :not(.x),b ::before{c:d}"},
+		{"descendant pseudo-class in media query", "@media print {\n  p :first-child { e: f }\n}", "@media print{p :first-child{e:f}}"},
+		{"brace in declaration string", "a { content : \"{\" }", "a{content:\"{\"}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minifyCSS(tt.src); got != tt.want {
+				t.Errorf("minifyCSS(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"block whitespace", "<ul>\n  <li>One</li>\n  <li>Two</li>\n</ul>\n", "<ul><li>One</li><li>Two</li></ul>"},
+		{"inline whitespace", "<p>Some  <em>very</em>\n  nice <a href=\"x\">text</a> .</p>", "<p>Some <em>very</em> nice <a href=\"x\">text</a> .</p>"},
+		{"comments", "<div><!-- note --> <p>x</p></div>", "<div><p>x</p></div>"},
+		{"attributes", "<a  class=\"a  b\"\n   href='c'>d</a>", "<a class=\"a  b\" href='c'>d</a>"},
+		{"pre is kept", "<pre>  a\n   b </pre>", "<pre>  a\n   b </pre>"},
+		{"script is kept", "<script>\n  if (a < b) { x = '</p>' }\n</script>", "<script>\n  if (a < b) { x = '</p>' }\n</script>"},
+		{"style is minified", "<style>\n  a { color: red; }\n</style>", "<style>a{color:red}</style>"},
+		{"text with less than", "<p>a < b</p>", "<p>a < b</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minifyHTML(tt.src); got != tt.want {
+				t.Errorf("minifyHTML(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package site
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/blog"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Open Graph card layout, in pixels.
+const (
+	cardWidth      = 1200
+	cardHeight     = 630
+	cardMargin     = 80
+	cardTitleSize  = 64
+	cardDetailSize = 32
+	cardMaxLines   = 5
+)
+
+// cardImageName returns the file name of the generated card for a post,
+// written next to the post page.
+func cardImageName(post blog.Post) string {
+	return post.Slug + ".og.png"
+}
+
+// generateCardImages renders an Open Graph card for every published post
+// without its own Image. Cards are cached by their inputs, so a card is only redrawn when
+// the title, date, card settings or the font and background files change.
+func (s *StaticSiteGenerator) generateCardImages(b *blog.Blog) error {
+	cfg := s.Config.Cards
+	if !cfg.Enabled {
+		return nil
+	}
+
+	cacheDir := cfg.CacheDir
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	files, err := s.cardFilesHash()
+	if err != nil {
+		return err
+	}
+
+	var faces *cardFaces
+	for _, post := range b.Posts {
+		if !post.Published || post.Image != "" {
+			continue
+		}
+
+		date := post.CreatedDate.Format("January 2, 2006")
+		cachePath := filepath.Join(cacheDir, s.cardCacheKey(post.Title, date, files)+".png")
+
+		if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+			// Load fonts lazily so fully cached builds never parse them
+			if faces == nil {
+				if faces, err = loadCardFaces(cfg.Font); err != nil {
+					return fmt.Errorf("error loading card font: %w", err)
+				}
+			}
+			if err := s.drawCard(faces, post.Title, date, cachePath); err != nil {
+				return fmt.Errorf("error drawing card for %s: %w", post.Slug, err)
+			}
+			fmt.Printf("Generated card image: %s\n", cardImageName(post))
+		} else if err != nil {
+			return err
+		}
+
+		if err := s.copyFile(cachePath, filepath.Join(s.OutputDir, cardImageName(post))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cardCacheKey derives the cache key of a card from everything drawn on it.
+// files is the hash of the font and background files, see cardFilesHash.
+func (s *StaticSiteGenerator) cardCacheKey(title, date, files string) string {
+	cfg := s.Config.Cards
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|%s|%s", title, date, s.Config.Site.Title,
+		cfg.Background, cfg.TextColor, files)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// cardFilesHash returns a hash of the contents of the card font and
+// background image, so replacing either file redraws the cards.
+func (s *StaticSiteGenerator) cardFilesHash() (string, error) {
+	h := sha256.New()
+	for _, path := range []string{s.Config.Cards.Font, s.Config.Cards.BackgroundImage} {
+		if path == "" {
+			h.Write([]byte("-|"))
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%d|", len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cardFaces holds the font faces used to draw cards.
+type cardFaces struct {
+	title  font.Face
+	detail font.Face
+}
+
+// loadCardFaces loads the TrueType or OpenType font at path, or the Go fonts
+// when path is empty.
+func loadCardFaces(path string) (*cardFaces, error) {
+	titleData, detailData := gobold.TTF, goregular.TTF
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		titleData, detailData = data, data
+	}
+
+	title, err := newFace(titleData, cardTitleSize)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := newFace(detailData, cardDetailSize)
+	if err != nil {
+		return nil, err
+	}
+	return &cardFaces{title: title, detail: detail}, nil
+}
+
+// newFace parses font data and returns a face of the given size.
+func newFace(data []byte, size float64) (font.Face, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// drawCard draws a card with the title, site name and date and writes it as
+// a PNG to path.
+func (s *StaticSiteGenerator) drawCard(faces *cardFaces, title, date, path string) error {
+	cfg := s.Config.Cards
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+
+	background, err := parseHexColor(cfg.Background)
+	if err != nil {
+		return err
+	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	if cfg.BackgroundImage != "" {
+		if err := drawBackgroundImage(img, cfg.BackgroundImage); err != nil {
+			return err
+		}
+	}
+
+	textColor, err := parseHexColor(cfg.TextColor)
+	if err != nil {
+		return err
+	}
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(textColor)}
+
+	// Title, wrapped to the card width from the top margin
+	drawer.Face = faces.title
+	lineHeight := faces.title.Metrics().Height.Ceil() + 8
+	y := cardMargin + faces.title.Metrics().Ascent.Ceil()
+	for _, line := range wrapText(faces.title, title, cardWidth-2*cardMargin, cardMaxLines) {
+		drawer.Dot = fixed.P(cardMargin, y)
+		drawer.DrawString(line)
+		y += lineHeight
+	}
+
+	// Site name and date along the bottom
+	drawer.Face = faces.detail
+	baseline := cardHeight - cardMargin
+	drawer.Dot = fixed.P(cardMargin, baseline)
+	drawer.DrawString(s.Config.Site.Title)
+	dateWidth := drawer.MeasureString(date).Ceil()
+	drawer.Dot = fixed.P(cardWidth-cardMargin-dateWidth, baseline)
+	drawer.DrawString(date)
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// drawBackgroundImage scales the image at path to cover the card, cropping
+// its center to the aspect ratio of the card.
+func drawBackgroundImage(dst *image.RGBA, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return err
+	}
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, coverCrop(src.Bounds(), dst.Bounds().Dx(), dst.Bounds().Dy()), xdraw.Over, nil)
+	return nil
+}
+
+// coverCrop returns the largest rectangle centered in bounds with the aspect
+// ratio of width by height.
+func coverCrop(bounds image.Rectangle, width, height int) image.Rectangle {
+	crop := bounds
+	if bounds.Dx()*height > bounds.Dy()*width {
+		w := bounds.Dy() * width / height
+		crop.Min.X = bounds.Min.X + (bounds.Dx()-w)/2
+		crop.Max.X = crop.Min.X + w
+	} else {
+		h := bounds.Dx() * height / width
+		crop.Min.Y = bounds.Min.Y + (bounds.Dy()-h)/2
+		crop.Max.Y = crop.Min.Y + h
+	}
+	return crop
+}
+
+// wrapText breaks text into lines no wider than width, ending with an
+// ellipsis if it needs more than maxLines lines.
+func wrapText(face font.Face, text string, width, maxLines int) []string {
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if current != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, current)
+			current = word
+		} else {
+			current = candidate
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] += "…"
+	}
+	return lines
+}
+
+// parseHexColor parses a color written as #rgb or #rrggbb.
+func parseHexColor(value string) (color.Color, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return nil, fmt.Errorf("invalid color %q, use #rgb or #rrggbb", value)
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q, use #rgb or #rrggbb", value)
+	}
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}, nil
+}
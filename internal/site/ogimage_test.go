@@ -0,0 +1,40 @@
+package site
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCoverCrop(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		want   image.Rectangle
+	}{
+		{"same ratio", image.Rect(0, 0, 2400, 1260), image.Rect(0, 0, 2400, 1260)},
+		{"wider", image.Rect(0, 0, 2000, 630), image.Rect(400, 0, 1600, 630)},
+		{"taller", image.Rect(0, 0, 1200, 1200), image.Rect(0, 285, 1200, 915)},
+		{"offset bounds", image.Rect(10, 10, 1210, 1210), image.Rect(10, 295, 1210, 925)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coverCrop(tt.bounds, cardWidth, cardHeight); got != tt.want {
+				t.Errorf("coverCrop(%v) = %v, want %v", tt.bounds, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCardImagesSkipDrafts(t *testing.T) {
+	ts := newTestSite(t)
+	ts.writePost(t, "public.md", "---\nCreatedDate: 2025-01-01\nPublished: true\n---\n# Public Post\n\nText.\n")
+	ts.writePost(t, "draft.md", "---\nCreatedDate: 2025-01-02\nPublished: false\n---\n# Draft Post\n\nText.\n")
+	ts.build(t)
+
+	if !ts.exists("public-post.og.png") {
+		t.Error("no card for the published post")
+	}
+	if ts.exists("draft-post.og.png") {
+		t.Error("card drawn for the draft")
+	}
+}
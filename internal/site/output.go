@@ -0,0 +1,158 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/manifest"
+)
+
+// writeOutput writes data to name, a slash-separated path relative to the
+// output directory, and records it as an output of the current build.
+func (s *StaticSiteGenerator) writeOutput(name string, data []byte) error {
+	dst := filepath.Join(s.OutputDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return err
+	}
+	s.track(dst)
+	return nil
+}
+
+// track records the file at dst, a path inside the output directory, as an
+// output of the current build.
+func (s *StaticSiteGenerator) track(dst string) {
+	rel, err := filepath.Rel(s.OutputDir, dst)
+	if err != nil {
+		return
+	}
+	if s.written == nil {
+		s.written = make(map[string]bool)
+	}
+	s.written[filepath.ToSlash(rel)] = true
+}
+
+// protected reports whether name, a slash-separated path relative to the
+// output directory, or one of its parent directories matches a pattern in
+// the output.protect setting.
+func (s *StaticSiteGenerator) protected(name string) bool {
+	for p := name; p != "." && p != "/"; p = path.Dir(p) {
+		for _, pattern := range s.Config.Output.Protect {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanOutput removes everything in the output directory except protected
+// paths.
+func (s *StaticSiteGenerator) cleanOutput() error {
+	return s.removeOutputs(func(string) bool { return true })
+}
+
+// pruneOutput removes the files the previous build wrote that the current
+// build did not, so renamed or deleted posts do not linger. Previous outputs
+// are the files marked as generated in the site manifest left in the output
+// directory, which is kept along with the site. Files placed in the output
+// directory by hand are not marked and are left alone, as are protected
+// paths.
+func (s *StaticSiteGenerator) pruneOutput() error {
+	previous, err := manifest.Load(filepath.Join(s.OutputDir, manifest.FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return s.removeOutputs(func(name string) bool {
+		e, ok := previous.Lookup(name)
+		return ok && e.Generated && !s.written[name]
+	})
+}
+
+// removeOutputs removes the unprotected files in the output directory for
+// which stale returns true, and then any directories left empty.
+func (s *StaticSiteGenerator) removeOutputs(stale func(name string) bool) error {
+	var dirs []string
+	err := filepath.Walk(s.OutputDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.OutputDir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == "." {
+			return nil
+		}
+		if s.protected(name) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if !stale(name) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		fmt.Printf("Removed stale file: %s\n", p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Remove the deepest directories first so emptied parents go too
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+			if err := os.Remove(dir); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSiteManifest writes the manifest of every file in the output
+// directory, with sizes and checksums, next to the site. Files written by
+// this build are marked as generated, so the next build can prune them.
+func (s *StaticSiteGenerator) writeSiteManifest() error {
+	m, err := manifest.Build(s.OutputDir)
+	if err != nil {
+		return err
+	}
+	for i := range m.Files {
+		m.Files[i].Generated = s.written[m.Files[i].Path]
+	}
+
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.writeOutput(manifest.FileName, data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated manifest: %s (%d files)\n", filepath.Join(s.OutputDir, manifest.FileName), len(m.Files))
+	return nil
+}
@@ -0,0 +1,120 @@
+package site
+
+import (
+	"time"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// generatePodcastFeed creates an RSS feed with the iTunes podcast extensions
+// from the published posts that have an audio attachment. No feed is written
+// when there are no episodes.
+func (s *StaticSiteGenerator) generatePodcastFeed(b *blog.Blog) error {
+	var episodes []blog.Post
+	for _, post := range b.Posts {
+		if post.Published && post.Audio != nil {
+			episodes = append(episodes, post)
+		}
+	}
+	if len(episodes) == 0 {
+		return nil
+	}
+
+	site := s.Config.Site
+	podcast := s.Config.Podcast
+	title := fallback(podcast.Title, site.Title)
+	description := fallback(podcast.Description, site.Description)
+	author := fallback(podcast.Author, site.Title)
+
+	channel := &RSSChannel{
+		Title: title,
+		Link:  site.BaseURL,
+		AtomLink: &AtomLink{
+			Href: s.absoluteURL("podcast.xml"),
+			Rel:  "self",
+			Type: "application/rss+xml",
+		},
+		Description:    description,
+		Language:       site.Language,
+		ITunesAuthor:   author,
+		ITunesSummary:  description,
+		ITunesExplicit: itunesExplicit(podcast.Explicit),
+		ITunesType:     "episodic",
+	}
+	if podcast.OwnerName != "" || podcast.OwnerEmail != "" {
+		channel.ITunesOwner = &ITunesOwner{
+			Name:  fallback(podcast.OwnerName, author),
+			Email: podcast.OwnerEmail,
+		}
+	}
+	if podcast.Image != "" {
+		channel.ITunesImage = &ITunesImage{Href: s.absoluteURL(podcast.Image)}
+	}
+	if podcast.Category != "" {
+		channel.ITunesCategory = &ITunesCategory{Text: podcast.Category}
+	}
+
+	var lastBuild time.Time
+	for _, post := range episodes {
+		pubDate := post.CreatedDate.Format(time.RFC1123Z)
+		if channel.PubDate == "" {
+			channel.PubDate = pubDate
+		}
+		if post.UpdatedDate.After(lastBuild) {
+			lastBuild = post.UpdatedDate
+		}
+
+		item := RSSItem{
+			Title:          post.Title,
+			Link:           s.postURL(post),
+			Description:    s.feedHTML(post, post.ContentSnippetHTML),
+			ContentEncoded: &RSSCDATA{Body: s.feedHTML(post, post.ContentHTML)},
+			PubDate:        pubDate,
+			GUID:           RSSGUID{Value: post.ID, IsPermaLink: "false"},
+			Categories:     post.Tags,
+			Enclosure:      s.enclosure(post.Audio.File, post.Audio.Size),
+			ITunesDuration: post.Audio.Duration,
+			ITunesEpisode:  post.Episode,
+			ITunesExplicit: itunesExplicit(post.Explicit || podcast.Explicit),
+		}
+		if post.Image != "" {
+			item.ITunesImage = &ITunesImage{Href: s.absoluteURL(post.Image)}
+		}
+		for _, author := range post.Authors {
+			item.Creators = append(item.Creators, author.Name)
+		}
+
+		channel.Items = append(channel.Items, item)
+	}
+
+	if !lastBuild.IsZero() {
+		channel.LastBuildDate = lastBuild.Format(time.RFC1123Z)
+	}
+
+	rss := &RSSFeed{
+		Version:      "2.0",
+		XmlnsDC:      "http://purl.org/dc/elements/1.1/",
+		XmlnsContent: "http://purl.org/rss/1.0/modules/content/",
+		XmlnsAtom:    "http://www.w3.org/2005/Atom",
+		XmlnsITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel:      channel,
+	}
+
+	return s.writeXMLFeed(rss, "podcast.xml", "podcast")
+}
+
+// itunesExplicit formats an explicit flag the way Apple Podcasts expects it.
+func itunesExplicit(explicit bool) string {
+	if explicit {
+		return "true"
+	}
+	return "false"
+}
+
+// fallback returns value, or def if value is empty.
+func fallback(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
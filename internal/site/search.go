@@ -0,0 +1,128 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/blog"
+)
+
+// Term weights by the part of the post a term occurs in.
+const (
+	searchWeightTitle   = 10
+	searchWeightTag     = 5
+	searchWeightHeading = 3
+	searchWeightText    = 1
+)
+
+// searchExcerptLength is the number of characters of plain text kept per
+// document for displaying results.
+const searchExcerptLength = 160
+
+// SearchIndex is the prebuilt full-text index read by the search page script.
+// Field names are kept short to keep the file compact.
+type SearchIndex struct {
+	StopWords []string         `json:"stop"`  // Words the script drops from queries
+	Docs      []SearchDoc      `json:"docs"`  // Searchable posts, referenced by position
+	Terms     map[string][]int `json:"terms"` // Stem to flat list of document index and score pairs
+}
+
+// SearchDoc describes a post in the search index.
+type SearchDoc struct {
+	Title    string   `json:"t"`
+	URL      string   `json:"u"`
+	Date     string   `json:"d"`
+	Tags     []string `json:"g,omitempty"`
+	Headings []string `json:"h,omitempty"`
+	Excerpt  string   `json:"x"`
+}
+
+// generateSearchIndex writes the search index of the published posts to
+// search-index.json. Terms are stemmed and stop words dropped with the same
+// rules the search script applies to queries.
+func (s *StaticSiteGenerator) generateSearchIndex(b *blog.Blog) error {
+	index := SearchIndex{
+		StopWords: blog.StopWords,
+		Docs:      make([]SearchDoc, 0),
+		Terms:     make(map[string][]int),
+	}
+
+	for _, post := range b.Posts {
+		if !post.Published {
+			continue
+		}
+
+		text := post.PlainText()
+		headings := post.Headings()
+		doc := SearchDoc{
+			Title:    post.Title,
+			URL:      post.Slug + ".html",
+			Date:     post.CreatedDate.Format("2006-01-02"),
+			Tags:     post.Tags,
+			Headings: headings,
+			Excerpt:  excerpt(text, searchExcerptLength),
+		}
+
+		scores := make(map[string]int)
+		addTerms(scores, post.Title, searchWeightTitle)
+		addTerms(scores, strings.Join(post.Tags, " "), searchWeightTag)
+		addTerms(scores, strings.Join(headings, " "), searchWeightHeading)
+		addTerms(scores, text, searchWeightText)
+
+		docIndex := len(index.Docs)
+		index.Docs = append(index.Docs, doc)
+
+		// Iterate terms in sorted order so the output is reproducible
+		terms := make([]string, 0, len(scores))
+		for term := range scores {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		for _, term := range terms {
+			index.Terms[term] = append(index.Terms[term], docIndex, scores[term])
+		}
+	}
+
+	output, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(s.OutputDir, "search-index.json")
+	if err := s.writeOutput("search-index.json", output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Generated search index: %s (%d terms)\n", filePath, len(index.Terms))
+	return nil
+}
+
+// generateSearchPage creates the search page from its template.
+func (s *StaticSiteGenerator) generateSearchPage() error {
+	meta := s.pageMeta("Search - "+s.Config.Site.Title, "", "search.html")
+	return s.renderPage("search.html", "search.html", PageData{Meta: meta})
+}
+
+// addTerms adds weight to the score of every term in text.
+func addTerms(scores map[string]int, text string, weight int) {
+	for _, term := range blog.Terms(text) {
+		scores[term] += weight
+	}
+}
+
+// excerpt shortens text to at most n characters, cutting at a word boundary.
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "..."
+}
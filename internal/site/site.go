@@ -6,6 +6,7 @@
 package site
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
@@ -13,27 +14,71 @@ import (
 	"path/filepath"
 
 	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
 )
 
 // StaticSiteGenerator handles generating static HTML from markdown posts.
 type StaticSiteGenerator struct {
 	PostsDir  string
 	OutputDir string
+	Config    *config.Config
+	Clean     bool // Empty the output directory before building
+
+	images  map[string][]imageVariant // Resized renditions keyed by image path
+	assets  map[string]AssetRef       // Page references to vendored assets keyed by path
+	missing map[string]bool           // Vendored assets whose files are missing, keyed by path
+	hashed  map[string]string         // Output names of theme assets keyed by template name
+	written map[string]bool           // Files written by the current build
 }
 
 // NewStaticSiteGenerator creates a new static site generator with the specified
-// posts directory and output directory.
-func NewStaticSiteGenerator(postsDir, outputDir string) *StaticSiteGenerator {
+// posts directory, output directory and site configuration.
+func NewStaticSiteGenerator(postsDir, outputDir string, cfg *config.Config) *StaticSiteGenerator {
 	return &StaticSiteGenerator{
 		PostsDir:  postsDir,
 		OutputDir: outputDir,
+		Config:    cfg,
 	}
 }
 
+// AuthorData holds the data for an author page.
+type AuthorData struct {
+	Author blog.Author
+	Posts  []blog.Post
+	Meta   PageMeta
+}
+
+// PostData holds the data for a post page. The post is embedded so templates
+// can refer to its fields directly.
+type PostData struct {
+	blog.Post
+	Site   config.SiteConfig
+	Meta   PageMeta
+	Assets PageAssets
+	Prev   *blog.PostRef // Next older published post, nil if there is none
+	Next   *blog.PostRef // Next newer published post, nil if there is none
+}
+
+// SeriesData holds the data for a series landing page.
+type SeriesData struct {
+	Series *blog.Series
+	Posts  []blog.Post
+	Meta   PageMeta
+	Assets PageAssets
+}
+
 // IndexData holds the data for the index page.
 type IndexData struct {
 	Posts       []blog.Post
 	LatestPosts []blog.Post
+	Meta        PageMeta
+	Assets      PageAssets
+}
+
+// PageData holds the data for pages without content of their own, such as
+// the search page.
+type PageData struct {
+	Meta PageMeta
 }
 
 // Generate generates the complete static site.
@@ -44,21 +89,80 @@ func (s *StaticSiteGenerator) Generate() error {
 	if err := os.MkdirAll(s.OutputDir, 0755); err != nil {
 		return err
 	}
+	s.written = make(map[string]bool)
 
 	// Load blog posts
 	b := blog.NewBlog(s.PostsDir)
+	b.GitDates = s.Config.Dates.FromGit
+	b.Location = s.Config.Location()
+	authors, err := blog.LoadAuthors(s.Config.Authors.File)
+	if err != nil {
+		return fmt.Errorf("error loading authors: %w", err)
+	}
+	b.Authors = authors
+	b.DefaultAuthor = s.Config.Authors.Default
 	if err := b.LoadPosts(); err != nil {
 		return fmt.Errorf("error loading posts: %w", err)
 	}
 
-	// Copy style.css to the output directory
-	if err := s.copyStyleCSS(); err != nil {
-		return fmt.Errorf("error copying style.css: %w", err)
+	// Suggest related posts at the end of every post
+	b.ComputeRelated(s.Config.Related.Count)
+
+	// Assign feed entry IDs. Publish never writes to the source tree, so
+	// posts without a stable ID are only reported
+	if assigned := assignFeedIDs(b.Posts, s.Config.Site.BaseURL); len(assigned) > 0 {
+		fmt.Printf("Warning: %d post(s) have no ID and get one that changes with their title (run 'musings fix')\n", len(assigned))
+		for _, post := range assigned {
+			if post.Published && post.CreatedByBuild {
+				fmt.Printf("Warning: %s has no CreatedDate or git history, so its feed ID changes with every build day (run 'musings fix')\n", post.SourcePath)
+			}
+		}
 	}
 
-	// Copy images directory to the output directory
-	if err := s.copyImages(); err != nil {
-		return fmt.Errorf("error copying images: %w", err)
+	// Check the vendored front-end assets before anything is written, so a
+	// page needing a missing one fails the build with the output untouched
+	vendored, err := s.loadVendorFiles(b)
+	if err != nil {
+		return fmt.Errorf("error loading vendored assets: %w", err)
+	}
+
+	if s.Clean {
+		if err := s.cleanOutput(); err != nil {
+			return fmt.Errorf("error cleaning output directory: %w", err)
+		}
+	}
+
+	// Write the minified, fingerprinted theme assets to the output directory
+	for _, name := range []string{"style.css", "search.js"} {
+		if err := s.writeThemeAsset(name); err != nil {
+			return fmt.Errorf("error writing %s: %w", name, err)
+		}
+	}
+
+	// Copy vendored front-end assets to the output directory
+	if err := s.copyVendorAssets(vendored); err != nil {
+		return fmt.Errorf("error copying vendored assets: %w", err)
+	}
+
+	// Copy images and generate resized variants in the output directory
+	if err := s.processImages(); err != nil {
+		return fmt.Errorf("error processing images: %w", err)
+	}
+
+	// Copy audio attachments to the output directory
+	if err := s.copyDir("audio"); err != nil {
+		return fmt.Errorf("error copying audio: %w", err)
+	}
+
+	// Draw social preview cards for posts without a cover image
+	if err := s.generateCardImages(b); err != nil {
+		return fmt.Errorf("error generating card images: %w", err)
+	}
+
+	// Point rendered images at their resized variants
+	for i := range b.Posts {
+		b.Posts[i].ContentHTML = s.responsiveImages(b.Posts[i].ContentHTML)
+		b.Posts[i].ContentSnippetHTML = s.responsiveImages(b.Posts[i].ContentSnippetHTML)
 	}
 
 	// Generate index page
@@ -71,6 +175,29 @@ func (s *StaticSiteGenerator) Generate() error {
 		return fmt.Errorf("error generating posts: %w", err)
 	}
 
+	// Generate author pages and feeds
+	if err := s.generateAuthors(b); err != nil {
+		return fmt.Errorf("error generating author pages: %w", err)
+	}
+
+	// Generate series landing pages
+	if err := s.generateSeries(b); err != nil {
+		return fmt.Errorf("error generating series pages: %w", err)
+	}
+
+	// Generate search index and page
+	if err := s.generateSearchIndex(b); err != nil {
+		return fmt.Errorf("error generating search index: %w", err)
+	}
+	if err := s.generateSearchPage(); err != nil {
+		return fmt.Errorf("error generating search page: %w", err)
+	}
+
+	// Generate the page served for missing paths
+	if err := s.generateNotFoundPage(b); err != nil {
+		return fmt.Errorf("error generating 404 page: %w", err)
+	}
+
 	// Generate RSS feed
 	if err := s.generateRSSFeed(b); err != nil {
 		return fmt.Errorf("error generating RSS feed: %w", err)
@@ -81,49 +208,47 @@ func (s *StaticSiteGenerator) Generate() error {
 		return fmt.Errorf("error generating Atom feed: %w", err)
 	}
 
-	return nil
-}
+	// Generate podcast feed
+	if err := s.generatePodcastFeed(b); err != nil {
+		return fmt.Errorf("error generating podcast feed: %w", err)
+	}
 
-// copyStyleCSS copies the style.css file to the output directory.
-func (s *StaticSiteGenerator) copyStyleCSS() error {
-	src := "internal/template/style.css"
-	dst := filepath.Join(s.OutputDir, "style.css")
+	// Generate JSON feed
+	if err := s.generateJSONFeed(b); err != nil {
+		return fmt.Errorf("error generating JSON feed: %w", err)
+	}
 
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return err
+	// Generate redirects and headers for the hosts the site is served from
+	if err := s.generateHostingRules(b); err != nil {
+		return fmt.Errorf("error generating hosting rules: %w", err)
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	// Remove outputs of earlier builds that this build did not produce
+	if err := s.pruneOutput(); err != nil {
+		return fmt.Errorf("error pruning output directory: %w", err)
+	}
+
+	// Write the manifest of the site with checksums for deploying
+	if err := s.writeSiteManifest(); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
 	}
-	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	return nil
 }
 
-// copyImages copies the images directory from posts to the output directory.
-func (s *StaticSiteGenerator) copyImages() error {
-	src := filepath.Join(s.PostsDir, "images")
-	dst := filepath.Join(s.OutputDir, "images")
+// copyDir copies the named directory from posts to the same place in the
+// output directory. A missing directory is not an error.
+func (s *StaticSiteGenerator) copyDir(name string) error {
+	src := filepath.Join(s.PostsDir, name)
+	dst := filepath.Join(s.OutputDir, name)
 
 	// Check if source directory exists
 	if _, err := os.Stat(src); os.IsNotExist(err) {
-		// No images directory, nothing to copy
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	// Create destination directory
-	if err := os.MkdirAll(dst, 0755); err != nil {
-		return err
-	}
-
-	// Copy all files in the images directory
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -134,22 +259,16 @@ func (s *StaticSiteGenerator) copyImages() error {
 			return nil
 		}
 
-		// Calculate relative path
 		relPath, err := filepath.Rel(src, path)
 		if err != nil {
 			return err
 		}
 
-		// Create destination file path
 		dstPath := filepath.Join(dst, relPath)
-
-		// Create destination directory if it doesn't exist
-		dstDir := filepath.Dir(dstPath)
-		if err := os.MkdirAll(dstDir, 0755); err != nil {
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
 			return err
 		}
 
-		// Copy file
 		return s.copyFile(path, dstPath)
 	})
 }
@@ -168,58 +287,180 @@ func (s *StaticSiteGenerator) copyFile(src, dst string) error {
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+	s.track(dst)
+	return nil
 }
 
-// generateIndex creates the index page with a list of all posts.
+// publishedPosts returns the published posts, newest first, as the index
+// and the 404 page list them.
+func publishedPosts(posts []blog.Post) []blog.Post {
+	var published []blog.Post
+	for _, post := range posts {
+		if post.Published {
+			published = append(published, post)
+		}
+	}
+	return published
+}
+
+// generateIndex creates the index page with a list of all published posts.
 func (s *StaticSiteGenerator) generateIndex(b *blog.Blog) error {
 	// Prepare data for the index page
+	posts := publishedPosts(b.Posts)
 	var latestPosts []blog.Post
-	if len(b.Posts) > 4 {
-		latestPosts = b.Posts[:4]
+	if len(posts) > 4 {
+		latestPosts = posts[:4]
 	} else {
-		latestPosts = b.Posts
+		latestPosts = posts
 	}
 
 	indexData := IndexData{
-		Posts:       b.Posts,
+		Posts:       posts,
 		LatestPosts: latestPosts,
+		Meta:        s.pageMeta(s.Config.Site.Title, "", ""),
+	}
+	snippets := make([]template.HTML, len(latestPosts))
+	for i, post := range latestPosts {
+		snippets[i] = post.ContentSnippetHTML
+	}
+	assets, err := s.pageAssets(snippets...)
+	if err != nil {
+		return fmt.Errorf("error rendering index.html: %w", err)
 	}
+	indexData.Assets = assets
 
-	tmpl, err := template.ParseFiles("internal/template/index.html")
+	return s.renderPage("index.html", "index.html", indexData)
+}
+
+// parseTemplate parses the named page template from the template directory
+// together with the shared partials it may use, such as "meta" and "styles".
+// Templates refer to theme assets through the asset function, which returns
+// their fingerprinted name.
+func (s *StaticSiteGenerator) parseTemplate(name string) (*template.Template, error) {
+	funcs := template.FuncMap{"asset": s.assetName}
+	return template.New(name).Funcs(funcs).ParseFiles(
+		filepath.Join("internal/template", name),
+		filepath.Join("internal/template", "meta.html"),
+		filepath.Join("internal/template", "assets.html"),
+	)
+}
+
+// renderPage executes the named template from the template directory with
+// data and writes the result to name in the output directory.
+func (s *StaticSiteGenerator) renderPage(templateName, name string, data any) error {
+	tmpl, err := s.parseTemplate(templateName)
 	if err != nil {
 		return err
 	}
+	return s.writePage(tmpl, name, data)
+}
 
-	file, err := os.Create(filepath.Join(s.OutputDir, "index.html"))
-	if err != nil {
+// writePage executes tmpl with data and writes the result, minified if
+// enabled, to name in the output directory.
+func (s *StaticSiteGenerator) writePage(tmpl *template.Template, name string, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	return tmpl.Execute(file, indexData)
+	page := buf.Bytes()
+	if s.Config.Assets.Minify {
+		page = []byte(minifyHTML(buf.String()))
+	}
+	return s.writeOutput(name, page)
 }
 
 // generatePosts creates individual HTML pages for each post.
 func (s *StaticSiteGenerator) generatePosts(b *blog.Blog) error {
-	tmpl, err := template.ParseFiles("internal/template/post.html")
+	tmpl, err := s.parseTemplate("post.html")
 	if err != nil {
 		return err
 	}
 
-	for _, post := range b.Posts {
-		file, err := os.Create(filepath.Join(s.OutputDir, post.Slug+".html"))
+	for i, post := range b.Posts {
+		assets, err := s.pageAssets(post.ContentHTML)
 		if err != nil {
+			return fmt.Errorf("error rendering %s: %w", post.SourcePath, err)
+		}
+		data := PostData{
+			Post:   post,
+			Site:   s.Config.Site,
+			Meta:   s.postMeta(post),
+			Assets: assets,
+		}
+		data.Prev, data.Next = b.Neighbors(i, s.Config.Nav.Within)
+
+		if err := s.writePage(tmpl, post.Slug+".html", data); err != nil {
 			return err
 		}
+	}
 
-		err = tmpl.Execute(file, post)
-		file.Close()
-		if err != nil {
+	return nil
+}
+
+// generateAuthors creates a listing page and RSS, Atom and JSON feeds for
+// every author with at least one published post.
+func (s *StaticSiteGenerator) generateAuthors(b *blog.Blog) error {
+	tmpl, err := s.parseTemplate("author.html")
+	if err != nil {
+		return err
+	}
+
+	for _, author := range b.PostAuthors() {
+		posts := b.PostsByAuthor(author.ID)
+		name := "author-" + author.Slug
+
+		meta := s.pageMeta(author.Name+" - "+s.Config.Site.Title, author.Bio, name+".html")
+		if author.Avatar != "" {
+			meta.Image = s.absoluteURL(author.Avatar)
+		}
+		meta.Creator = author.Twitter
+
+		data := AuthorData{Author: author, Posts: posts, Meta: meta}
+		if err := s.writePage(tmpl, name+".html", data); err != nil {
+			return err
+		}
+
+		title := fmt.Sprintf("%s - %s", author.Name, s.Config.Site.Title)
+		if err := s.writeRSSFeed(posts, name+"-rss.xml", title); err != nil {
+			return err
+		}
+		if err := s.writeAtomFeed(posts, name+"-atom.xml", title, &author); err != nil {
+			return err
+		}
+		if err := s.writeJSONFeed(posts, name+".json", title, &author); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// generateSeries creates a landing page for every series listing its parts.
+func (s *StaticSiteGenerator) generateSeries(b *blog.Blog) error {
+	for _, series := range b.Series {
+		name := "series-" + series.Slug + ".html"
+		posts := b.PostsInSeries(series.Slug)
+		snippets := make([]template.HTML, len(posts))
+		for i, post := range posts {
+			snippets[i] = post.ContentSnippetHTML
+		}
+		assets, err := s.pageAssets(snippets...)
+		if err != nil {
+			return fmt.Errorf("error rendering %s: %w", name, err)
+		}
+		data := SeriesData{
+			Series: series,
+			Posts:  posts,
+			Meta:   s.pageMeta(series.Name+" - "+s.Config.Site.Title, "", name),
+			Assets: assets,
+		}
+		if err := s.renderPage("series.html", name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+// testSite is a site built by the tests from posts in a temporary directory
+// with the theme of the repository.
+type testSite struct {
+	cfg      *config.Config
+	postsDir string
+	outDir   string
+}
+
+// newTestSite returns a site with temporary posts, output and cache
+// directories. Builds run from the repository root, where the theme is.
+func newTestSite(t *testing.T) *testSite {
+	t.Helper()
+	dir := t.TempDir()
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	cfg := config.Default()
+	cfg.Site.BaseURL = "https://example.com"
+	cfg.Authors.File = filepath.Join(dir, "authors.yaml")
+	cfg.Cards.CacheDir = filepath.Join(dir, "cache", "cards")
+	cfg.Images.CacheDir = filepath.Join(dir, "cache", "images")
+	cfg.Hosting.Dir = filepath.Join(dir, "hosting")
+
+	return &testSite{
+		cfg:      cfg,
+		postsDir: filepath.Join(dir, "posts"),
+		outDir:   filepath.Join(dir, "public"),
+	}
+}
+
+// writePost writes a post file below the posts directory.
+func (ts *testSite) writePost(t *testing.T, name, content string) {
+	t.Helper()
+	path := filepath.Join(ts.postsDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// removePost deletes a post file from the posts directory.
+func (ts *testSite) removePost(t *testing.T, name string) {
+	t.Helper()
+	if err := os.Remove(filepath.Join(ts.postsDir, filepath.FromSlash(name))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// build publishes the site and returns the generator that built it.
+func (ts *testSite) build(t *testing.T) *StaticSiteGenerator {
+	t.Helper()
+	s := NewStaticSiteGenerator(ts.postsDir, ts.outDir, ts.cfg)
+	if err := s.Generate(); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return s
+}
+
+// exists reports whether name, a slash-separated path in the output
+// directory, exists.
+func (ts *testSite) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(ts.outDir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+func TestGeneratePrunesRemovedPosts(t *testing.T) {
+	ts := newTestSite(t)
+	ts.writePost(t, "one.md", "---\nCreatedDate: 2025-01-01\nPublished: true\n---\n# First Post\n\nOne.\n")
+	ts.writePost(t, "two.md", "---\nCreatedDate: 2025-01-02\nPublished: true\n---\n# Second Post\n\nTwo.\n")
+	ts.build(t)
+	if !ts.exists("second-post.html") {
+		t.Fatal("first build did not write second-post.html")
+	}
+
+	// Files placed by hand are kept
+	if err := os.WriteFile(filepath.Join(ts.outDir, "robots.txt"), []byte("User-agent: *\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.removePost(t, "two.md")
+	ts.build(t)
+	if ts.exists("second-post.html") {
+		t.Error("page of the removed post is still in the output")
+	}
+	if !ts.exists("first-post.html") {
+		t.Error("page of the remaining post was removed")
+	}
+	if !ts.exists("robots.txt") {
+		t.Error("file placed by hand was removed")
+	}
+}
+
+func TestDraftsLeftOutOfListings(t *testing.T) {
+	ts := newTestSite(t)
+	ts.writePost(t, "public.md", "---\nCreatedDate: 2025-01-01\nPublished: true\n---\n# Public Post\n\nText.\n")
+	ts.writePost(t, "draft.md", "---\nCreatedDate: 2025-01-02\nPublished: false\n---\n# Draft Post\n\nText.\n")
+	ts.build(t)
+
+	for _, name := range []string{"index.html", "404.html"} {
+		data, err := os.ReadFile(filepath.Join(ts.outDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "public-post.html") {
+			t.Errorf("%s does not link to the published post", name)
+		}
+		if strings.Contains(string(data), "draft-post.html") {
+			t.Errorf("%s links to the draft", name)
+		}
+	}
+}
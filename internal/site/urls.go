@@ -0,0 +1,51 @@
+package site
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// urlAttrPattern matches href, src and srcset attributes in rendered HTML.
+var urlAttrPattern = regexp.MustCompile(`\b(href|src|srcset)="([^"]*)"`)
+
+// absolutizeHTML rewrites every relative href, src and srcset URL in content
+// into an absolute URL resolved against base.
+func absolutizeHTML(content, base string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return content
+	}
+
+	return urlAttrPattern.ReplaceAllStringFunc(content, func(attr string) string {
+		m := urlAttrPattern.FindStringSubmatch(attr)
+		name, value := m[1], m[2]
+
+		if name != "srcset" {
+			return name + `="` + resolveURL(baseURL, value) + `"`
+		}
+
+		// srcset holds comma separated "url descriptor" candidates
+		candidates := strings.Split(value, ",")
+		for i, candidate := range candidates {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+			fields[0] = resolveURL(baseURL, fields[0])
+			candidates[i] = strings.Join(fields, " ")
+		}
+		return name + `="` + strings.Join(candidates, ", ") + `"`
+	})
+}
+
+// resolveURL resolves ref against base, leaving absolute URLs and refs that
+// cannot be parsed unchanged. The &amp; entity is decoded before parsing and
+// encoded again afterwards so query strings survive the round trip.
+func resolveURL(base *url.URL, ref string) string {
+	u, err := url.Parse(strings.ReplaceAll(ref, "&amp;", "&"))
+	if err != nil || u.IsAbs() {
+		return ref
+	}
+	return strings.ReplaceAll(base.ResolveReference(u).String(), "&", "&amp;")
+}
@@ -0,0 +1,196 @@
+package syndicate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+func init() {
+	Register("devto", func(cfg config.SyncConfig) (Platform, error) {
+		key := os.Getenv("DEVTO_API_KEY")
+		if key == "" {
+			return nil, errors.New("DEVTO_API_KEY is not set")
+		}
+		return NewDevTo(cfg.DevTo.URL, key), nil
+	})
+}
+
+// DevTo is a client for the DEV (dev.to) API, also served by other Forem
+// communities.
+type DevTo struct {
+	BaseURL string // API base URL, e.g. https://dev.to
+	APIKey  string
+	Client  *http.Client
+}
+
+// NewDevTo creates a DEV client for the API at baseURL.
+func NewDevTo(baseURL, apiKey string) *DevTo {
+	return &DevTo{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, Client: http.DefaultClient}
+}
+
+// devToArticle is an article as returned by the API.
+type devToArticle struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	URL          string `json:"url"`
+	CanonicalURL string `json:"canonical_url"`
+	BodyMarkdown string `json:"body_markdown"`
+	Published    bool   `json:"published"`
+}
+
+// remote converts the article to a RemotePost.
+func (a devToArticle) remote() RemotePost {
+	return RemotePost{
+		ID:           strconv.Itoa(a.ID),
+		Title:        a.Title,
+		URL:          a.URL,
+		CanonicalURL: a.CanonicalURL,
+		Markdown:     a.BodyMarkdown,
+		Published:    a.Published,
+	}
+}
+
+// devToUpdate holds the fields of an article sent to the API. Fields that
+// are not set are left as they are.
+type devToUpdate struct {
+	Title        string   `json:"title,omitempty"`
+	BodyMarkdown string   `json:"body_markdown,omitempty"`
+	Published    *bool    `json:"published,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Series       string   `json:"series,omitempty"`
+	CanonicalURL string   `json:"canonical_url,omitempty"`
+}
+
+// Name implements Platform.
+func (d *DevTo) Name() string {
+	return "devto"
+}
+
+// Authenticate implements Platform.
+func (d *DevTo) Authenticate() error {
+	return d.do(http.MethodGet, "/api/users/me", nil, nil)
+}
+
+// List implements Platform. It returns published and unpublished articles.
+func (d *DevTo) List() ([]RemotePost, error) {
+	var posts []RemotePost
+	for page := 1; ; page++ {
+		var articles []devToArticle
+		if err := d.do(http.MethodGet, fmt.Sprintf("/api/articles/me/all?page=%d&per_page=1000", page), nil, &articles); err != nil {
+			return nil, err
+		}
+		for _, a := range articles {
+			posts = append(posts, a.remote())
+		}
+		if len(articles) < 1000 {
+			return posts, nil
+		}
+	}
+}
+
+// Create implements Platform.
+func (d *DevTo) Create(p Post) (RemotePost, error) {
+	var a devToArticle
+	err := d.do(http.MethodPost, "/api/articles", map[string]devToUpdate{"article": d.article(p)}, &a)
+	return a.remote(), err
+}
+
+// Update implements Platform.
+func (d *DevTo) Update(id string, p Post) (RemotePost, error) {
+	var a devToArticle
+	err := d.do(http.MethodPut, "/api/articles/"+id, map[string]devToUpdate{"article": d.article(p)}, &a)
+	return a.remote(), err
+}
+
+// Delete implements Platform. The API cannot delete articles, so they are
+// unpublished instead.
+func (d *DevTo) Delete(id string) error {
+	published := false
+	return d.do(http.MethodPut, "/api/articles/"+id, map[string]devToUpdate{"article": {Published: &published}}, nil)
+}
+
+// article converts p to an article. DEV accepts at most four tags made of
+// lowercase letters and digits.
+func (d *DevTo) article(p Post) devToUpdate {
+	published := !p.Draft
+	a := devToUpdate{
+		Title:        p.Title,
+		BodyMarkdown: p.Markdown,
+		Published:    &published,
+		Description:  p.Description,
+		CanonicalURL: p.CanonicalURL,
+		Series:       p.Series,
+	}
+	for _, tag := range p.Tags {
+		tag = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, strings.ToLower(tag))
+		if tag != "" && len(a.Tags) < 4 {
+			a.Tags = append(a.Tags, tag)
+		}
+	}
+	return a
+}
+
+// do sends a request with a JSON body, if not nil, and decodes the JSON
+// response into out, if not nil.
+func (d *DevTo) do(method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, d.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("api-key", d.APIKey)
+	req.Header.Set("Accept", "application/vnd.forem.api-v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// decodeResponse decodes the JSON body of a successful response into out,
+// if not nil, and turns other responses into errors.
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		msg := resp.Status
+		if detail := strings.TrimSpace(string(data)); detail != "" {
+			msg += ": " + detail
+		}
+		return fmt.Errorf("%s %s: %s", resp.Request.Method, resp.Request.URL.Path, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response of %s: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package syndicate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// fakeDevTo is a stand-in for the DEV API holding articles in memory.
+type fakeDevTo struct {
+	articles []map[string]any
+	requests []*http.Request
+}
+
+func (f *fakeDevTo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	if r.Header.Get("api-key") != "key" {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/users/me":
+		fmt.Fprint(w, `{"id":1,"username":"alice"}`)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/articles/me/all":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		start := min((page-1)*perPage, len(f.articles))
+		end := min(start+perPage, len(f.articles))
+		json.NewEncoder(w).Encode(f.articles[start:end])
+	case r.Method == http.MethodPost && r.URL.Path == "/api/articles":
+		var body struct{ Article map[string]any }
+		json.NewDecoder(r.Body).Decode(&body)
+		a := body.Article
+		a["id"] = len(f.articles) + 1
+		a["url"] = fmt.Sprintf("https://dev.to/alice/%d", a["id"])
+		a["tags"] = "stringified, by, the, api"
+		f.articles = append(f.articles, a)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a)
+	case r.Method == http.MethodPut:
+		var id int
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/articles/%d", &id); err != nil || id < 1 || id > len(f.articles) {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct{ Article map[string]any }
+		json.NewDecoder(r.Body).Decode(&body)
+		for k, v := range body.Article {
+			f.articles[id-1][k] = v
+		}
+		json.NewEncoder(w).Encode(f.articles[id-1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestDevTo(t *testing.T) (*DevTo, *fakeDevTo) {
+	t.Helper()
+	fake := &fakeDevTo{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return NewDevTo(srv.URL+"/", "key"), fake
+}
+
+func TestDevToAuthenticate(t *testing.T) {
+	d, _ := newTestDevTo(t)
+	if err := d.Authenticate(); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	d.APIKey = "wrong"
+	if err := d.Authenticate(); err == nil {
+		t.Fatal("Authenticate with a wrong key succeeded")
+	}
+}
+
+func TestDevToCreateUpdateDelete(t *testing.T) {
+	d, fake := newTestDevTo(t)
+	post := Post{
+		Title:        "Hello",
+		Markdown:     "Some *text*.",
+		Description:  "A greeting",
+		Tags:         []string{"Go", "web-dev", "c++", "a", "b"},
+		CanonicalURL: "https://example.com/hello.html",
+	}
+
+	created, err := d.Create(post)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if created.ID != "1" || created.URL != "https://dev.to/alice/1" || !created.Published {
+		t.Errorf("Create returned %+v", created)
+	}
+	a := fake.articles[0]
+	if got, want := a["tags"], "stringified, by, the, api"; got != want {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+	if got, want := fake.requests[0].Header.Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+
+	post.Markdown = "Other text."
+	if _, err := d.Update(created.ID, post); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := fake.articles[0]["body_markdown"]; got != "Other text." {
+		t.Errorf("body_markdown after Update = %v", got)
+	}
+
+	if err := d.Delete(created.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := fake.articles[0]["published"]; got != false {
+		t.Errorf("published after Delete = %v, want false", got)
+	}
+
+	if _, err := d.Update("7", post); err == nil {
+		t.Error("Update of a missing article succeeded")
+	}
+}
+
+func TestDevToArticleTags(t *testing.T) {
+	d := NewDevTo("https://dev.to", "key")
+	a := d.article(Post{Tags: []string{"Go", "web-dev", "c++", "!!", "a", "b"}, Draft: true})
+	if want := []string{"go", "webdev", "c", "a"}; !reflect.DeepEqual(a.Tags, want) {
+		t.Errorf("tags = %q, want %q", a.Tags, want)
+	}
+	if a.Published == nil || *a.Published {
+		t.Error("draft post is published")
+	}
+}
+
+func TestDevToListPages(t *testing.T) {
+	d, fake := newTestDevTo(t)
+	for i := 1; i <= 1001; i++ {
+		fake.articles = append(fake.articles, map[string]any{
+			"id":            i,
+			"title":         fmt.Sprintf("Post %d", i),
+			"canonical_url": fmt.Sprintf("https://example.com/%d.html", i),
+			"body_markdown": "text",
+			"published":     true,
+		})
+	}
+
+	posts, err := d.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(posts) != 1001 {
+		t.Fatalf("List returned %d posts, want 1001", len(posts))
+	}
+	want := RemotePost{ID: "1001", Title: "Post 1001", CanonicalURL: "https://example.com/1001.html", Markdown: "text", Published: true}
+	if posts[1000] != want {
+		t.Errorf("last post = %+v, want %+v", posts[1000], want)
+	}
+}
@@ -0,0 +1,194 @@
+package syndicate
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/config"
+)
+
+func init() {
+	Register("medium", func(cfg config.SyncConfig) (Platform, error) {
+		token := os.Getenv("MEDIUM_TOKEN")
+		if token == "" {
+			return nil, errors.New("MEDIUM_TOKEN is not set")
+		}
+		return NewMedium(cfg.Medium.URL, cfg.Medium.FeedURL, token), nil
+	})
+}
+
+// Medium is a client for the Medium API. The API can only create posts, so
+// published posts are listed from the profile feed, which reports neither
+// their content nor their canonical URL, and posts are never updated or
+// deleted.
+type Medium struct {
+	BaseURL string // API base URL, e.g. https://api.medium.com
+	FeedURL string // Base URL of profile feeds, e.g. https://medium.com
+	Token   string
+	Client  *http.Client
+
+	userID   string // Set by Authenticate
+	username string
+}
+
+// NewMedium creates a Medium client for the API at baseURL and the feeds
+// at feedURL.
+func NewMedium(baseURL, feedURL, token string) *Medium {
+	return &Medium{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		FeedURL: strings.TrimSuffix(feedURL, "/"),
+		Token:   token,
+		Client:  http.DefaultClient,
+	}
+}
+
+// mediumPost is a post as returned by the API.
+type mediumPost struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	URL           string `json:"url"`
+	CanonicalURL  string `json:"canonicalUrl"`
+	PublishStatus string `json:"publishStatus"`
+}
+
+// Name implements Platform.
+func (m *Medium) Name() string {
+	return "medium"
+}
+
+// Authenticate implements Platform and looks up the user posts are
+// published for.
+func (m *Medium) Authenticate() error {
+	var resp struct {
+		Data struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := m.do(http.MethodGet, "/v1/me", nil, &resp); err != nil {
+		return err
+	}
+	m.userID, m.username = resp.Data.ID, resp.Data.Username
+	return nil
+}
+
+// List implements Platform with the posts in the profile feed of the user,
+// which holds the latest published posts.
+func (m *Medium) List() ([]RemotePost, error) {
+	if m.username == "" {
+		return nil, errors.New("medium: not authenticated")
+	}
+	resp, err := m.Client.Get(m.FeedURL + "/feed/@" + m.username)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching feed of @%s: %s", m.username, resp.Status)
+	}
+
+	var feed struct {
+		Items []struct {
+			Title string `xml:"title"`
+			Link  string `xml:"link"`
+			GUID  string `xml:"guid"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("error parsing feed of @%s: %w", m.username, err)
+	}
+
+	posts := make([]RemotePost, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		// The GUID is https://medium.com/p/<id>
+		id := item.GUID[strings.LastIndex(item.GUID, "/")+1:]
+		posts = append(posts, RemotePost{ID: id, Title: item.Title, URL: item.Link, Published: true})
+	}
+	return posts, nil
+}
+
+// Create implements Platform. The content starts with the title, as Medium
+// shows the first heading as the title of the post.
+func (m *Medium) Create(p Post) (RemotePost, error) {
+	if m.userID == "" {
+		return RemotePost{}, errors.New("medium: not authenticated")
+	}
+
+	status := "public"
+	if p.Draft {
+		status = "draft"
+	}
+	tags := p.Tags
+	if len(tags) > 5 {
+		tags = tags[:5]
+	}
+	body := map[string]any{
+		"title":         p.Title,
+		"contentFormat": "markdown",
+		"content":       "# " + p.Title + "\n\n" + p.Markdown,
+		"canonicalUrl":  p.CanonicalURL,
+		"tags":          tags,
+		"publishStatus": status,
+	}
+
+	var resp struct {
+		Data mediumPost `json:"data"`
+	}
+	if err := m.do(http.MethodPost, "/v1/users/"+m.userID+"/posts", body, &resp); err != nil {
+		return RemotePost{}, err
+	}
+	return RemotePost{
+		ID:           resp.Data.ID,
+		Title:        resp.Data.Title,
+		URL:          resp.Data.URL,
+		CanonicalURL: resp.Data.CanonicalURL,
+		Published:    resp.Data.PublishStatus == "public",
+	}, nil
+}
+
+// Update implements Platform. The Medium API cannot change posts.
+func (m *Medium) Update(id string, p Post) (RemotePost, error) {
+	return RemotePost{}, fmt.Errorf("updating posts on medium: %w", ErrUnsupported)
+}
+
+// Delete implements Platform. The Medium API cannot delete posts.
+func (m *Medium) Delete(id string) error {
+	return fmt.Errorf("deleting posts on medium: %w", ErrUnsupported)
+}
+
+// do sends a request to the API with a JSON body, if not nil, and decodes
+// the JSON response into out, if not nil.
+func (m *Medium) do(method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, m.BaseURL+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
@@ -0,0 +1,124 @@
+package syndicate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// mediumFeed is a profile feed as served by Medium.
+const mediumFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Stories by Alice on Medium</title>
+<item>
+<title><![CDATA[Hello]]></title>
+<link>https://medium.com/@alice/hello-abc123?source=rss</link>
+<guid isPermaLink="false">https://medium.com/p/abc123</guid>
+</item>
+</channel></rss>`
+
+func newTestMedium(t *testing.T) (*Medium, *map[string]any) {
+	t.Helper()
+	var created map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"errors":[{"message":"Token was invalid."}]}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"id":"u1","username":"alice"}}`)
+	})
+	mux.HandleFunc("GET /feed/@alice", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, mediumFeed)
+	})
+	mux.HandleFunc("POST /v1/users/u1/posts", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&created)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"data":{"id":"def456","title":%q,"url":"https://medium.com/@alice/def456","canonicalUrl":%q,"publishStatus":%q}}`,
+			created["title"], created["canonicalUrl"], created["publishStatus"])
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return NewMedium(srv.URL, srv.URL, "token"), &created
+}
+
+func TestMediumAuthenticate(t *testing.T) {
+	m, _ := newTestMedium(t)
+	m.Token = "wrong"
+	if err := m.Authenticate(); err == nil {
+		t.Fatal("Authenticate with a wrong token succeeded")
+	}
+
+	m.Token = "token"
+	if err := m.Authenticate(); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if m.userID != "u1" || m.username != "alice" {
+		t.Errorf("user = %q/%q, want u1/alice", m.userID, m.username)
+	}
+}
+
+func TestMediumList(t *testing.T) {
+	m, _ := newTestMedium(t)
+	if _, err := m.List(); err == nil {
+		t.Fatal("List before Authenticate succeeded")
+	}
+	if err := m.Authenticate(); err != nil {
+		t.Fatal(err)
+	}
+
+	posts, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	want := RemotePost{ID: "abc123", Title: "Hello", URL: "https://medium.com/@alice/hello-abc123?source=rss", Published: true}
+	if len(posts) != 1 || posts[0] != want {
+		t.Errorf("List = %+v, want [%+v]", posts, want)
+	}
+}
+
+func TestMediumCreate(t *testing.T) {
+	m, created := newTestMedium(t)
+	if err := m.Authenticate(); err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := m.Create(Post{
+		Title:        "Hello",
+		Markdown:     "Some text.",
+		Tags:         []string{"a", "b", "c", "d", "e", "f"},
+		CanonicalURL: "https://example.com/hello.html",
+		Draft:        true,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	want := RemotePost{ID: "def456", Title: "Hello", URL: "https://medium.com/@alice/def456", CanonicalURL: "https://example.com/hello.html"}
+	if post != want {
+		t.Errorf("Create = %+v, want %+v", post, want)
+	}
+
+	body := *created
+	if got, want := body["content"], "# Hello\n\nSome text."; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	if got := body["publishStatus"]; got != "draft" {
+		t.Errorf("publishStatus = %v, want draft", got)
+	}
+	if tags := body["tags"].([]any); len(tags) != 5 {
+		t.Errorf("sent %d tags, want 5", len(tags))
+	}
+}
+
+func TestMediumUnsupported(t *testing.T) {
+	m, _ := newTestMedium(t)
+	if _, err := m.Update("abc123", Post{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Update error = %v, want ErrUnsupported", err)
+	}
+	if err := m.Delete("abc123"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Delete error = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,163 @@
+package syndicate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Record is what the state remembers about the copy of a post on a platform.
+type Record struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	CanonicalURL string    `json:"canonical_url"` // Recognizes the post when its source moves
+	Hash         string    `json:"hash"`          // Hash of the post as last sent
+	SyncedAt     time.Time `json:"synced_at"`
+}
+
+// remote returns the remote post the record describes.
+func (r Record) remote() RemotePost {
+	return RemotePost{ID: r.ID, Title: r.Title, URL: r.URL, Published: true}
+}
+
+// State maps local posts, by source path, to their copies on every platform.
+// It is kept in a file between syncs so posts are recognized even when a
+// platform does not list them, and only changed posts are sent again.
+type State struct {
+	path  string
+	posts map[string]map[string]Record
+}
+
+// LoadState reads the state file at path. A missing file yields an empty
+// state.
+func LoadState(path string) (*State, error) {
+	s := &State{path: path, posts: make(map[string]map[string]Record)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.posts); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns the record of the post at source on the platform.
+func (s *State) Get(source, platform string) (Record, bool) {
+	r, ok := s.posts[source][platform]
+	return r, ok
+}
+
+// Set records the copy of the post at source on the platform.
+func (s *State) Set(source, platform string, r Record) {
+	if s.posts[source] == nil {
+		s.posts[source] = make(map[string]Record)
+	}
+	s.posts[source][platform] = r
+}
+
+// Remove forgets the copy of the post at source on the platform.
+func (s *State) Remove(source, platform string) {
+	delete(s.posts[source], platform)
+	if len(s.posts[source]) == 0 {
+		delete(s.posts, source)
+	}
+}
+
+// Sources returns the source paths of the posts with records on the
+// platform, sorted.
+func (s *State) Sources(platform string) []string {
+	var sources []string
+	for source, records := range s.posts {
+		if _, ok := records[platform]; ok {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Platforms returns the names of the platforms the state has records for,
+// sorted.
+func (s *State) Platforms() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, records := range s.posts {
+		for name := range records {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Applied updates the state after the action a was applied on the platform
+// and returned the remote post r.
+func (s *State) Applied(platform string, a Action, r RemotePost) {
+	if a.Post.Source == "" {
+		return
+	}
+	if a.From != "" {
+		s.Remove(a.From, platform)
+	}
+	if a.Kind == Delete {
+		s.Remove(a.Post.Source, platform)
+		return
+	}
+	if r.ID == "" {
+		r.ID = a.Remote.ID
+	}
+	if r.URL == "" {
+		r.URL = a.Remote.URL
+	}
+	s.Set(a.Post.Source, platform, Record{
+		ID:           r.ID,
+		URL:          r.URL,
+		Title:        a.Post.Title,
+		CanonicalURL: a.Post.CanonicalURL,
+		Hash:         Hash(a.Post),
+		SyncedAt:     time.Now().UTC().Truncate(time.Second),
+	})
+}
+
+// Save writes the state back to its file.
+func (s *State) Save() error {
+	// Map keys are marshalled in sorted order, keeping the file stable
+	data, err := json.MarshalIndent(s.posts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Hash returns a hash of the parts of p that are sent to platforms, so
+// changed posts can be told from those already in sync.
+func Hash(p Post) string {
+	data, _ := json.Marshal(struct {
+		Title, Markdown, Description, Series, CanonicalURL string
+		Tags                                               []string
+	}{p.Title, p.Markdown, p.Description, p.Series, p.CanonicalURL, p.Tags})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
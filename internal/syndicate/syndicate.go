@@ -0,0 +1,337 @@
+// Package syndicate publishes posts to external blogging platforms.
+//
+// Every platform is a client implementing Platform, registered under the
+// name posts use in their Syndicate frontmatter key. A sync compares the
+// local posts with those listed by the platform and builds a plan of posts
+// to create, update and delete, which is shown before it is executed.
+// Remote copies link back to the site with their canonical URL. A State
+// file remembers the copy of every post on each platform and the hash of
+// the content last sent, so repeated syncs only send changed posts and
+// never create a second copy.
+package syndicate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/m4xw311/musing/internal/blog"
+	"github.com/m4xw311/musing/internal/config"
+)
+
+// ErrUnsupported is returned by platforms for operations their API does not
+// offer.
+var ErrUnsupported = errors.New("not supported by the platform")
+
+// Post is a local post as sent to a platform.
+type Post struct {
+	Source       string // Path of the source file, which keys the post in the State
+	Slug         string // Identifies the post on the site
+	Title        string
+	Markdown     string // Content without the title heading, with absolute links
+	Description  string
+	Tags         []string
+	Series       string
+	CanonicalURL string // URL of the post on the site
+	Draft        bool   // Create the post unpublished
+}
+
+// RemotePost is a post on a platform.
+type RemotePost struct {
+	ID           string
+	Title        string
+	URL          string
+	CanonicalURL string // Empty if the platform does not report it
+	Markdown     string // Empty if the platform does not report it
+	Published    bool
+}
+
+// Platform is a client for a blogging platform.
+type Platform interface {
+	// Name returns the name posts use to syndicate to the platform.
+	Name() string
+
+	// Authenticate checks the credentials of the client.
+	Authenticate() error
+
+	// List returns the posts of the authenticated user.
+	List() ([]RemotePost, error)
+
+	// Create publishes a new post.
+	Create(p Post) (RemotePost, error)
+
+	// Update replaces the post with the given ID.
+	Update(id string, p Post) (RemotePost, error)
+
+	// Delete removes the post with the given ID.
+	Delete(id string) error
+}
+
+// Factory creates a platform client from the configuration.
+type Factory func(cfg config.SyncConfig) (Platform, error)
+
+// registry holds the factories of the known platforms by name.
+var registry = make(map[string]Factory)
+
+// Register makes a platform available under name. It is meant to be called
+// from the init function of the file implementing the platform.
+func Register(name string, f Factory) {
+	if _, ok := registry[name]; ok {
+		panic("syndicate: platform registered twice: " + name)
+	}
+	registry[name] = f
+}
+
+// Platforms returns the names of the registered platforms, sorted.
+func Platforms() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the client for the named platform.
+func New(name string, cfg config.SyncConfig) (Platform, error) {
+	f, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown platform %q (available: %s)", name, strings.Join(Platforms(), ", "))
+	}
+	return f(cfg)
+}
+
+// ActionKind is the kind of change a sync makes to a remote post.
+type ActionKind string
+
+// The kinds of action in a plan.
+const (
+	Create ActionKind = "create"
+	Update ActionKind = "update"
+	Delete ActionKind = "delete"
+	Link   ActionKind = "link" // Record an existing remote post in the State
+)
+
+// Action is a change to one post on a platform.
+type Action struct {
+	Kind   ActionKind
+	Post   Post       // Local post; only Source is set for deletes, if known
+	Remote RemotePost // Matching remote post, empty for creates
+	From   string     // Previous source of a moved post, whose record it takes over
+}
+
+// Title returns the title of the post the action applies to.
+func (a Action) Title() string {
+	if a.Kind == Delete {
+		return a.Remote.Title
+	}
+	return a.Post.Title
+}
+
+// Options controls how a plan is built.
+type Options struct {
+	BaseURL string // Site URL; remote posts below it with no local post are removed
+	Delete  bool   // Remove remote posts whose local post no longer syndicates to the platform
+}
+
+// BuildPlan returns the actions that bring the remote posts of the named
+// platform in line with the local posts syndicated to it. Posts with a
+// record in state are updated when their hash changed, whether or not the
+// platform lists them. Other posts take over the record of a post whose
+// source file moved, or are matched to remote posts by canonical URL, or by
+// title for platforms that do not report it, and linked to them, or updated
+// if the platform reports their content and it differs.
+func BuildPlan(platform string, local []Post, remote []RemotePost, state *State, opts Options) []Action {
+	syndicated := make(map[string]bool)
+	for _, p := range local {
+		syndicated[p.Source] = true
+	}
+
+	// Records of sources that are gone or no longer syndicate to the
+	// platform, which moved posts take over
+	var orphans []string
+	for _, source := range state.Sources(platform) {
+		if !syndicated[source] {
+			orphans = append(orphans, source)
+		}
+	}
+	claimed := make(map[string]bool)
+	orphan := func(p Post, id string) (string, Record, bool) {
+		for _, source := range orphans {
+			rec, _ := state.Get(source, platform)
+			if !claimed[source] && (rec.CanonicalURL != "" && rec.CanonicalURL == p.CanonicalURL || id != "" && rec.ID == id) {
+				claimed[source] = true
+				return source, rec, true
+			}
+		}
+		return "", Record{}, false
+	}
+
+	matched := make(map[int]bool)
+	linked := make(map[string]bool)
+	var actions []Action
+	for _, p := range local {
+		rec, ok := state.Get(p.Source, platform)
+		var from string
+		if !ok {
+			i := match(p, remote, matched)
+			var id string
+			if i >= 0 {
+				id = remote[i].ID
+			}
+			from, rec, ok = orphan(p, id)
+			if !ok && i >= 0 {
+				matched[i] = true
+				linked[id] = true
+				r := remote[i]
+				if r.Markdown != "" && (r.Title != p.Title || strings.TrimSpace(r.Markdown) != strings.TrimSpace(p.Markdown)) {
+					actions = append(actions, Action{Kind: Update, Post: p, Remote: r})
+				} else {
+					actions = append(actions, Action{Kind: Link, Post: p, Remote: r})
+				}
+				continue
+			}
+		}
+		if !ok {
+			actions = append(actions, Action{Kind: Create, Post: p})
+			continue
+		}
+
+		linked[rec.ID] = true
+		r := rec.remote()
+		if i := findID(remote, rec.ID); i >= 0 {
+			matched[i] = true
+			r = remote[i]
+		}
+		switch {
+		case rec.Hash != Hash(p):
+			actions = append(actions, Action{Kind: Update, Post: p, Remote: r, From: from})
+		case from != "":
+			actions = append(actions, Action{Kind: Link, Post: p, Remote: r, From: from})
+		}
+	}
+
+	if !opts.Delete {
+		return actions
+	}
+
+	// Copies of posts that no longer syndicate to the platform, or were
+	// removed, are known from the state
+	for _, source := range orphans {
+		rec, _ := state.Get(source, platform)
+		if claimed[source] || linked[rec.ID] {
+			continue
+		}
+		if i := findID(remote, rec.ID); i >= 0 {
+			matched[i] = true
+		}
+		actions = append(actions, Action{Kind: Delete, Post: Post{Source: source}, Remote: rec.remote()})
+	}
+	if opts.BaseURL != "" {
+		prefix := strings.TrimSuffix(opts.BaseURL, "/") + "/"
+		for i, r := range remote {
+			if !matched[i] && !linked[r.ID] && r.Published && strings.HasPrefix(r.CanonicalURL, prefix) {
+				actions = append(actions, Action{Kind: Delete, Remote: r})
+			}
+		}
+	}
+	return actions
+}
+
+// findID returns the index of the remote post with the given ID, or -1.
+func findID(remote []RemotePost, id string) int {
+	for i, r := range remote {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// match returns the index of the remote post matching p that is not matched
+// yet, or -1.
+func match(p Post, remote []RemotePost, matched map[int]bool) int {
+	for i, r := range remote {
+		if !matched[i] && r.CanonicalURL != "" && r.CanonicalURL == p.CanonicalURL {
+			return i
+		}
+	}
+	for i, r := range remote {
+		if !matched[i] && r.CanonicalURL == "" && r.Title == p.Title {
+			return i
+		}
+	}
+	return -1
+}
+
+// Apply executes an action on the platform and returns the remote post it
+// created or changed.
+func Apply(pl Platform, a Action) (RemotePost, error) {
+	switch a.Kind {
+	case Create:
+		return pl.Create(a.Post)
+	case Update:
+		return pl.Update(a.Remote.ID, a.Post)
+	case Delete:
+		return a.Remote, pl.Delete(a.Remote.ID)
+	case Link:
+		return a.Remote, nil
+	}
+	return RemotePost{}, fmt.Errorf("unknown action %q", a.Kind)
+}
+
+// CheckBaseURL returns an error unless baseURL is the public URL of the
+// site, since remote copies link back to the site with it.
+func CheckBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	host := u.Hostname()
+	if host == "localhost" || net.ParseIP(host) != nil || !strings.Contains(host, ".") {
+		return fmt.Errorf("site.base_url must be the public URL of the site, got %q", baseURL)
+	}
+	return nil
+}
+
+// FromBlog returns post as sent to platforms, with the relative links of its
+// content resolved against the site URL baseURL.
+func FromBlog(post blog.Post, baseURL string, draft bool) Post {
+	base := strings.TrimSuffix(baseURL, "/") + "/"
+	return Post{
+		Source:       filepath.ToSlash(post.SourcePath),
+		Slug:         post.Slug,
+		Title:        post.Title,
+		Markdown:     absoluteLinks(strings.TrimSpace(post.Content), base),
+		Description:  post.Description,
+		Tags:         post.Tags,
+		Series:       post.Series,
+		CanonicalURL: base + post.Slug + ".html",
+		Draft:        draft,
+	}
+}
+
+// markdownLink matches the target of markdown links and images.
+var markdownLink = regexp.MustCompile(`(\]\()([^)\s]+)`)
+
+// absoluteLinks resolves the relative link and image targets in markdown
+// against base, since the content is shown on another site.
+func absoluteLinks(markdown, base string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return markdown
+	}
+	return markdownLink.ReplaceAllStringFunc(markdown, func(m string) string {
+		parts := markdownLink.FindStringSubmatch(m)
+		target, err := url.Parse(parts[2])
+		if err != nil || target.IsAbs() || strings.HasPrefix(parts[2], "#") {
+			return m
+		}
+		return parts[1] + baseURL.ResolveReference(target).String()
+	})
+}
@@ -0,0 +1,192 @@
+package syndicate
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// kinds returns the kind and title of every action.
+func kinds(actions []Action) []string {
+	var out []string
+	for _, a := range actions {
+		out = append(out, string(a.Kind)+" "+a.Title())
+	}
+	return out
+}
+
+func TestBuildPlan(t *testing.T) {
+	hello := Post{Source: "posts/hello.md", Title: "Hello", Markdown: "Hi.", CanonicalURL: "https://example.com/hello.html"}
+	moved := hello
+	moved.Source = "posts/2025/hello.md"
+	edited := hello
+	edited.Markdown = "Hi again."
+	world := Post{Source: "posts/world.md", Title: "World", Markdown: "Earth.", CanonicalURL: "https://example.com/world.html"}
+
+	remoteHello := RemotePost{ID: "1", Title: "Hello", CanonicalURL: hello.CanonicalURL, Markdown: "Hi.", Published: true}
+	remoteWorld := RemotePost{ID: "2", Title: "World", CanonicalURL: world.CanonicalURL, Markdown: "Earth.", Published: true}
+	helloRecord := Record{ID: "1", Title: "Hello", CanonicalURL: hello.CanonicalURL, Hash: Hash(hello)}
+	oldRecord := helloRecord
+	oldRecord.CanonicalURL = ""
+
+	tests := []struct {
+		name    string
+		local   []Post
+		remote  []RemotePost
+		records map[string]Record
+		delete  bool
+		want    []string
+	}{
+		{
+			name:  "new post",
+			local: []Post{hello},
+			want:  []string{"create Hello"},
+		},
+		{
+			name:   "existing remote post is linked",
+			local:  []Post{hello},
+			remote: []RemotePost{remoteHello},
+			want:   []string{"link Hello"},
+		},
+		{
+			name:   "existing remote post that differs is updated",
+			local:  []Post{edited},
+			remote: []RemotePost{remoteHello},
+			want:   []string{"update Hello"},
+		},
+		{
+			name:    "recorded post is up to date",
+			local:   []Post{hello},
+			remote:  []RemotePost{remoteHello},
+			records: map[string]Record{hello.Source: helloRecord},
+			want:    nil,
+		},
+		{
+			name:    "recorded post not listed by the platform is not created again",
+			local:   []Post{hello},
+			records: map[string]Record{hello.Source: helloRecord},
+			want:    nil,
+		},
+		{
+			name:    "recorded post that changed is updated",
+			local:   []Post{edited},
+			records: map[string]Record{hello.Source: helloRecord},
+			want:    []string{"update Hello"},
+		},
+		{
+			name:    "removed post is deleted",
+			remote:  []RemotePost{remoteHello},
+			records: map[string]Record{hello.Source: helloRecord},
+			delete:  true,
+			want:    []string{"delete Hello"},
+		},
+		{
+			name:    "removed post is kept without delete",
+			remote:  []RemotePost{remoteHello},
+			records: map[string]Record{hello.Source: helloRecord},
+			want:    nil,
+		},
+		{
+			name:    "moved post takes over the record",
+			local:   []Post{moved},
+			remote:  []RemotePost{remoteHello},
+			records: map[string]Record{hello.Source: helloRecord},
+			delete:  true,
+			want:    []string{"link Hello"},
+		},
+		{
+			name:    "moved post not listed by the platform takes over the record",
+			local:   []Post{moved},
+			records: map[string]Record{hello.Source: helloRecord},
+			delete:  true,
+			want:    []string{"link Hello"},
+		},
+		{
+			name:    "moved post with a record without canonical URL",
+			local:   []Post{moved},
+			remote:  []RemotePost{remoteHello},
+			records: map[string]Record{hello.Source: oldRecord},
+			delete:  true,
+			want:    []string{"link Hello"},
+		},
+		{
+			name:   "unknown remote post below the site is deleted",
+			local:  []Post{hello},
+			remote: []RemotePost{remoteHello, remoteWorld},
+			delete: true,
+			want:   []string{"link Hello", "delete World"},
+		},
+		{
+			name:   "remote posts of other sites are kept",
+			remote: []RemotePost{{ID: "3", Title: "Elsewhere", CanonicalURL: "https://other.example/x.html", Published: true}},
+			delete: true,
+			want:   nil,
+		},
+		{
+			name:   "posts without canonical URL are matched by title",
+			local:  []Post{world},
+			remote: []RemotePost{{ID: "4", Title: "World", Published: true}},
+			want:   []string{"link World"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := LoadState(filepath.Join(t.TempDir(), "sync.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for source, rec := range tt.records {
+				state.Set(source, "devto", rec)
+			}
+
+			actions := BuildPlan("devto", tt.local, tt.remote, state, Options{BaseURL: "https://example.com", Delete: tt.delete})
+			if got := kinds(actions); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppliedMovesRecord(t *testing.T) {
+	state, err := LoadState(filepath.Join(t.TempDir(), "sync.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Set("posts/hello.md", "devto", Record{ID: "1", URL: "https://dev.to/alice/1", Title: "Hello"})
+
+	post := Post{Source: "posts/2025/hello.md", Title: "Hello", CanonicalURL: "https://example.com/hello.html"}
+	rec, _ := state.Get("posts/hello.md", "devto")
+	state.Applied("devto", Action{Kind: Link, Post: post, Remote: rec.remote(), From: "posts/hello.md"}, rec.remote())
+
+	if _, ok := state.Get("posts/hello.md", "devto"); ok {
+		t.Error("record of the old source is kept")
+	}
+	rec, ok := state.Get(post.Source, "devto")
+	if !ok || rec.ID != "1" || rec.URL != "https://dev.to/alice/1" || rec.Hash != Hash(post) {
+		t.Errorf("record of the new source = %+v, %v", rec, ok)
+	}
+}
+
+func TestCheckBaseURL(t *testing.T) {
+	for url, ok := range map[string]bool{
+		"https://example.com":      true,
+		"https://blog.example.com": true,
+		"http://localhost:8080":    false,
+		"http://127.0.0.1:8080":    false,
+		"http://[::1]/":            false,
+		"http://intranet/":         false,
+	} {
+		if err := CheckBaseURL(url); (err == nil) != ok {
+			t.Errorf("CheckBaseURL(%q) = %v", url, err)
+		}
+	}
+}
+
+func TestAbsoluteLinks(t *testing.T) {
+	got := absoluteLinks("![a](images/a.png) [b](other.html#x) [c](#top) [d](https://x.example/)", "https://example.com/blog/")
+	want := "![a](https://example.com/blog/images/a.png) [b](https://example.com/blog/other.html#x) [c](#top) [d](https://x.example/)"
+	if got != want {
+		t.Errorf("absoluteLinks = %q, want %q", got, want)
+	}
+}
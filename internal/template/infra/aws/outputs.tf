@@ -0,0 +1,19 @@
+output "bucket_name" {
+  description = "Bucket to set as deploy.s3.bucket"
+  value       = aws_s3_bucket.site.id
+}
+
+output "distribution_id" {
+  description = "ID of the CloudFront distribution, for invalidations"
+  value       = aws_cloudfront_distribution.site.id
+}
+
+output "distribution_domain_name" {
+  description = "Domain name of the CloudFront distribution"
+  value       = aws_cloudfront_distribution.site.domain_name
+}
+
+output "site_url" {
+  description = "URL the site is served from"
+  value       = "https://${var.domain_name}/"
+}
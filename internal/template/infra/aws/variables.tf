@@ -0,0 +1,43 @@
+variable "domain_name" {
+  description = "Domain name the site is served from, e.g. blog.example.com"
+  type        = string
+}
+
+variable "zone_name" {
+  description = "Route53 hosted zone the domain belongs to, e.g. example.com"
+  type        = string
+}
+
+variable "bucket_name" {
+  description = "Name of the S3 bucket holding the site"
+  type        = string
+}
+
+variable "region" {
+  description = "AWS region of the bucket"
+  type        = string
+  default     = "us-east-1"
+}
+
+variable "origin_path" {
+  description = "Key prefix the site is stored under in the bucket, starting with a slash, or empty"
+  type        = string
+  default     = ""
+}
+
+variable "price_class" {
+  description = "CloudFront price class: PriceClass_100, PriceClass_200 or PriceClass_All"
+  type        = string
+  default     = "PriceClass_100"
+
+  validation {
+    condition     = contains(["PriceClass_100", "PriceClass_200", "PriceClass_All"], var.price_class)
+    error_message = "The price_class must be PriceClass_100, PriceClass_200 or PriceClass_All."
+  }
+}
+
+variable "cloudfront_function_file" {
+  description = "CloudFront Function for redirects and headers, relative to the module, or empty for none"
+  type        = string
+  default     = ""
+}
@@ -0,0 +1,20 @@
+terraform {
+  required_version = ">= 1.3"
+
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 5.0"
+    }
+  }
+}
+
+provider "aws" {
+  region = var.region
+}
+
+# CloudFront only accepts certificates from us-east-1
+provider "aws" {
+  alias  = "us_east_1"
+  region = "us-east-1"
+}
@@ -0,0 +1,170 @@
+// Client-side search over the search-index.json file generated at publish time.
+//
+// Queries are tokenized, filtered and stemmed with the same rules the
+// generator applies to posts (see internal/blog/terms.go); keep both in sync.
+(function () {
+    "use strict";
+
+    // Suffix rewrites as [suffix, replacement, minimum stem length], tried in order
+    var STEM_RULES = [
+        ["ational", "ate", 2],
+        ["tional", "tion", 2],
+        ["iveness", "ive", 2],
+        ["fulness", "ful", 2],
+        ["ousness", "ous", 2],
+        ["ization", "ize", 2],
+        ["ation", "ate", 3],
+        ["ement", "", 4],
+        ["ment", "", 4],
+        ["ness", "", 3],
+        ["ings", "", 3],
+        ["ing", "", 3],
+        ["edly", "", 3],
+        ["ied", "y", 2],
+        ["ies", "y", 2],
+        ["sses", "ss", 2],
+        ["ed", "", 3],
+        ["ly", "", 3],
+        ["ss", "ss", 2],
+        ["us", "us", 2],
+        ["is", "is", 2],
+        ["s", "", 3],
+    ];
+
+    var MAX_RESULTS = 20;
+
+    function stem(word) {
+        var length = Array.from(word).length;
+        for (var i = 0; i < STEM_RULES.length; i++) {
+            var rule = STEM_RULES[i];
+            if (word.endsWith(rule[0]) && length - rule[0].length >= rule[2]) {
+                return word.slice(0, word.length - rule[0].length) + rule[1];
+            }
+        }
+        return word;
+    }
+
+    function terms(text, stopWords) {
+        return text
+            .toLowerCase()
+            .split(/[^\p{L}\p{N}]+/u)
+            .filter(function (w) {
+                return Array.from(w).length >= 2 && !stopWords.has(w);
+            })
+            .map(stem);
+    }
+
+    // Scores documents containing every query term. The last term also
+    // matches as a prefix so results update while typing.
+    function search(index, stopWords, query) {
+        var queryTerms = terms(query, stopWords);
+        if (queryTerms.length === 0) {
+            return [];
+        }
+
+        var scores = null;
+        queryTerms.forEach(function (term, i) {
+            var keys = [term];
+            if (i === queryTerms.length - 1) {
+                keys = Object.keys(index.terms).filter(function (key) {
+                    return key.startsWith(term);
+                });
+            }
+
+            var termScores = new Map();
+            keys.forEach(function (key) {
+                var postings = index.terms[key] || [];
+                for (var j = 0; j < postings.length; j += 2) {
+                    var doc = postings[j];
+                    termScores.set(doc, (termScores.get(doc) || 0) + postings[j + 1]);
+                }
+            });
+
+            if (scores === null) {
+                scores = termScores;
+                return;
+            }
+            var merged = new Map();
+            scores.forEach(function (score, doc) {
+                if (termScores.has(doc)) {
+                    merged.set(doc, score + termScores.get(doc));
+                }
+            });
+            scores = merged;
+        });
+
+        return Array.from(scores.entries())
+            .sort(function (a, b) {
+                return b[1] - a[1];
+            })
+            .slice(0, MAX_RESULTS)
+            .map(function (entry) {
+                return index.docs[entry[0]];
+            });
+    }
+
+    function render(results, query, list, status) {
+        list.textContent = "";
+        if (query.trim() === "") {
+            status.textContent = "";
+            return;
+        }
+
+        status.textContent =
+            results.length === 0
+                ? "No posts found."
+                : results.length + " post" + (results.length === 1 ? "" : "s") + " found.";
+
+        results.forEach(function (doc) {
+            var item = document.createElement("li");
+
+            var link = document.createElement("a");
+            link.href = doc.u;
+            link.textContent = doc.t;
+            item.appendChild(link);
+            item.appendChild(document.createTextNode(" - " + doc.d));
+
+            if (doc.g && doc.g.length > 0) {
+                var tags = document.createElement("div");
+                tags.className = "tags";
+                tags.textContent = doc.g.join(", ");
+                item.appendChild(tags);
+            }
+
+            var excerpt = document.createElement("p");
+            excerpt.textContent = doc.x;
+            item.appendChild(excerpt);
+
+            list.appendChild(item);
+        });
+    }
+
+    document.addEventListener("DOMContentLoaded", function () {
+        var input = document.getElementById("search-input");
+        var list = document.getElementById("search-results");
+        var status = document.getElementById("search-status");
+        if (!input || !list || !status) {
+            return;
+        }
+
+        status.textContent = "Loading search index...";
+        fetch("search-index.json")
+            .then(function (response) {
+                return response.json();
+            })
+            .then(function (index) {
+                var stopWords = new Set(index.stop);
+                var update = function () {
+                    render(search(index, stopWords, input.value), input.value, list, status);
+                };
+
+                input.value = new URLSearchParams(window.location.search).get("q") || "";
+                input.addEventListener("input", update);
+                update();
+                input.focus();
+            })
+            .catch(function () {
+                status.textContent = "The search index could not be loaded.";
+            });
+    });
+})();